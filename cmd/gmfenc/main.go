@@ -0,0 +1,83 @@
+// gmfenc 加密配置值, 输出可直接粘贴到 config.toml 的 "enc:..." 字符串。
+//
+//	GMF_MASTER_KEY=xxx gmfenc 'my password'
+//	echo -n 'my password' | gmfenc -keyfile conf/master.key
+//	gmfenc -d 'enc:...'
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wyy8261/gmf/conf"
+)
+
+func main() {
+	var (
+		key     = flag.String("key", "", "master key, default from $"+conf.MasterKeyEnv)
+		keyFile = flag.String("keyfile", "", "master key file, default from $"+conf.MasterKeyFileEnv+" or "+conf.DefaultMasterKeyFile)
+		decrypt = flag.Bool("d", false, "decrypt an enc: value instead")
+	)
+	flag.Parse()
+
+	//命令行参数优先于环境变量
+	var (
+		masterKey []byte
+		err       error
+	)
+	switch {
+	case *key != "":
+		masterKey = []byte(*key)
+	case *keyFile != "":
+		masterKey, err = readKeyFile(*keyFile)
+	default:
+		masterKey, err = conf.MasterKey()
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	val := strings.Join(flag.Args(), " ")
+	if flag.NArg() == 0 {
+		data, err := io.ReadAll(bufio.NewReader(os.Stdin))
+		if err != nil {
+			fail(err)
+		}
+		val = strings.TrimRight(string(data), "\r\n")
+	}
+	if val == "" {
+		fail(fmt.Errorf("empty value"))
+	}
+
+	if *decrypt {
+		val, err = conf.DecryptSecret(val, masterKey)
+	} else {
+		val, err = conf.EncryptSecret(val, masterKey)
+	}
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println(val)
+}
+
+// readKeyFile 读取 -keyfile 指定的密钥文件, 不受 $GMF_MASTER_KEY 影响
+func readKeyFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, fmt.Errorf("empty key file: %s", name)
+	}
+	return []byte(key), nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "gmfenc:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,12 @@
+// Package autoload 保留旧版导入conf即加载配置的行为:
+// 切换工作目录到可执行文件所在目录后按默认路径加载配置。
+// 加载发生在 flag.Parse() 之前, profile 只能通过环境变量 GMF_PROFILE 选择。
+//
+//	import _ "github.com/wyy8261/gmf/conf/autoload"
+package autoload
+
+import "github.com/wyy8261/gmf/conf"
+
+func init() {
+	conf.Load(conf.Options{Chdir: true})
+}
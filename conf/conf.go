@@ -1,20 +1,35 @@
 package conf
 
 import (
+	"errors"
 	"fmt"
-	"github.com/BurntSushi/toml"
 	"github.com/wyy8261/gmf/logger"
+	"io/fs"
 	"os"
-	"path"
-	"path/filepath"
-	"runtime"
+	"reflect"
+	"strings"
+	"sync/atomic"
 )
 
 var (
-	gConf  *Config
-	gExtra interface{}
+	gState atomic.Pointer[state]
+	gPath  string
 )
 
+// state 一次加载得到的配置快照, 热加载时整体替换
+type state struct {
+	cfg     *Config
+	files   []string
+	tree    map[string]interface{}
+	origins map[string]string
+	sources []Source
+	err     error
+	//加载时使用的路径及选项, 供监听协程无锁读取
+	path     string
+	fsys     fs.FS
+	profiles []string
+}
+
 type ServerInfo struct {
 	IP     string
 	Port   int
@@ -54,46 +69,118 @@ type Config struct {
 	BaseURL  string
 	Oss      string
 	AesKey   string
+	AesKeyId string            //AesKey 的密钥ID, 加密时写入信封, 默认 "0"
+	AesKeys  map[string]string //密钥轮换期间仍可解密的旧密钥, 密钥ID -> 密钥, 对应 [AesKeys] 表
 	Redis    ServerInfo
 	Mssql    ServerInfo
 	RabbitMQ ServerInfo
 	Mongo    ServerInfo
 	TLS      TLSInfo
 	Aly      AlyConf
+	Log      logger.Config
+	//具名连接块, 块名 -> 实例名 -> 连接信息, 由 [Mssql.log] [Redis.session] 这类子表加载
+	Named map[string]map[string]ServerInfo `toml:"-"`
+}
+
+// 支持具名实例的连接块
+var namedBlocks = []string{"Redis", "Mssql", "RabbitMQ", "Mongo"}
+
+// Server 返回block(如"Mssql")下名为name的连接信息, name为空时返回不具名的默认块
+func (c *Config) Server(block, name string) (ServerInfo, bool) {
+	if name == "" {
+		v, ok := fieldByPath(reflect.ValueOf(c).Elem(), block)
+		if !ok {
+			return ServerInfo{}, false
+		}
+		info, ok := v.Interface().(ServerInfo)
+		return info, ok
+	}
+	for b, m := range c.Named {
+		if strings.EqualFold(b, block) {
+			info, ok := m[name]
+			return info, ok
+		}
+	}
+	return ServerInfo{}, false
 }
 
 func (c *Config) Addr() string {
 	return fmt.Sprintf("%s:%d", c.IP, c.Port)
 }
 
+// Default 返回当前生效的配置, 未调用 Load 时按默认参数加载
 func Default() *Config {
-	if gConf != nil {
-		return gConf
-	}
-	return &Config{}
+	return current().cfg
 }
 
-func Extra[T any]() *T {
-	if gExtra != nil {
-		v, ok := gExtra.(*T)
-		if ok {
-			return v
+// Validate 按已注册的规则校验配置
+func (c *Config) Validate() error {
+	return Validate(c)
+}
+
+// LoadError 返回启动加载配置时的错误(文件缺失、格式错误及校验失败汇总)
+func LoadError() error {
+	return current().err
+}
+
+// loadState 按profile叠加配置文件解码出一份新的配置, 并叠加环境变量
+func loadState(cpath string) (*state, error) {
+	profiles := gOpts.Profiles
+	if profiles == nil {
+		profiles = Profile()
+	}
+	st := &state{cfg: &Config{}, files: profileFiles(gOpts.FS, cpath, profiles), path: cpath, fsys: gOpts.FS, profiles: profiles}
+	tree, origins, err := loadTree(gOpts.FS, st.files)
+	st.tree, st.origins = tree, origins
+	//有远程配置源时允许没有本地配置文件
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && len(gOpts.Sources) > 0) {
+		return st, err
+	}
+	st.sources = sources(tree)
+	remote := false
+	for _, src := range st.sources {
+		if rt := readSource(src); rt != nil {
+			mergeTree(tree, typedTree(rt, reflect.TypeOf(Config{}), "", src.Name()), "", src.Name(), st.origins)
+			remote = true
 		}
 	}
-	var cpath string = "conf/config.toml"
-	if !IsExist(cpath) {
-		_, filename, _, ok := runtime.Caller(0)
-		if ok {
-			cpath = path.Join(path.Dir(filename), "config.toml")
+	if err != nil && !remote {
+		return st, err
+	}
+	if err = decodeTree(tree, st.cfg); err != nil {
+		return st, err
+	}
+	if err = decodeNamed(tree, st.cfg); err != nil {
+		return st, err
+	}
+	//环境变量优先于配置文件
+	if err = applyEnvStruct(EnvPrefix, "", reflect.ValueOf(st.cfg).Elem(), st.origins); err != nil {
+		return st, err
+	}
+	for block, m := range st.cfg.Named {
+		for name, info := range m {
+			prefix := strings.ToUpper(EnvPrefix + "_" + block + "_" + name)
+			keyPath := strings.ToLower(block + "." + name)
+			if err = applyEnvStruct(prefix, keyPath, reflect.ValueOf(&info).Elem(), st.origins); err != nil {
+				return st, err
+			}
+			m[name] = info
 		}
 	}
-	conf := new(T)
-	if _, err := toml.DecodeFile(cpath, conf); err != nil {
+	if err = decryptSecrets(st.cfg); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+// Extra 把整个配置文件解码为T, 结果按类型缓存; 只需要其中一张表时使用 Section
+func Extra[T any]() *T {
+	v, err := Section[T]("")
+	if err != nil {
 		logger.LOGE("err:", err)
 		return nil
 	}
-	gExtra = conf
-	return conf
+	return v
 }
 
 func IsExist(f string) bool {
@@ -101,32 +188,30 @@ func IsExist(f string) bool {
 	return err == nil || os.IsExist(err)
 }
 
-func init() {
-	// 获取程序的命令行参数
-	args := os.Args
-	// 获取绝对路径
-	absolutePath, err := filepath.Abs(args[0])
-	if err != nil {
-		logger.LOGE("err:", err)
-		return
-	}
-	execPath := path.Dir(absolutePath)
-	//切换执行路径
-	os.Chdir(execPath)
-	logger.LOGD("Chdir:", execPath)
-
-	gConf = Default()
-	var cpath string = "conf/config.toml"
-	if !IsExist(cpath) {
-		_, filename, _, ok := runtime.Caller(0)
-		if ok {
-			cpath = path.Join(path.Dir(filename), "config.toml")
+// decodeNamed 把连接块下的子表解码为具名实例
+func decodeNamed(tree map[string]interface{}, c *Config) error {
+	for _, block := range namedBlocks {
+		bm, ok := tree[findKey(tree, block)].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, v := range bm {
+			sub, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var info ServerInfo
+			if err := decodeTree(sub, &info); err != nil {
+				return fmt.Errorf("%s.%s: %w", block, name, err)
+			}
+			if c.Named == nil {
+				c.Named = make(map[string]map[string]ServerInfo)
+			}
+			if c.Named[block] == nil {
+				c.Named[block] = make(map[string]ServerInfo)
+			}
+			c.Named[block][name] = info
 		}
 	}
-
-	if _, err := toml.DecodeFile(cpath, &gConf); err != nil {
-		logger.LOGE("err:", err)
-		return
-	}
-	return
+	return nil
 }
@@ -0,0 +1,148 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix 环境变量前缀, 如 GMF_REDIS_PWD 覆盖 Config.Redis.Pwd
+const EnvPrefix = "GMF"
+
+// ApplyEnv 用环境变量覆盖v(结构体指针)中的字段。
+// 变量名为 prefix 加上逐级字段名(优先取toml标签)的大写形式, 以下划线连接,
+// 例如 prefix=GMF 时 Config.Aly.BucketName 对应 GMF_ALY_BUCKETNAME。
+func ApplyEnv(prefix string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("conf: ApplyEnv needs a non-nil pointer, got %T", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("conf: ApplyEnv needs a pointer to struct, got %T", v)
+	}
+	return applyEnvStruct(strings.ToUpper(prefix), "", rv, nil)
+}
+
+// applyEnvStruct 递归覆盖结构体字段, origins 非nil时记录被覆盖字段的来源
+func applyEnvStruct(prefix, keyPath string, rv reflect.Value, origins map[string]string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := envFieldName(field)
+		if name == "" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "_" + name
+		}
+		kp := strings.ToLower(name)
+		if keyPath != "" {
+			kp = keyPath + "." + kp
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			if err := applyEnvStruct(key, kp, fv, origins); err != nil {
+				return err
+			}
+			continue
+		}
+		val, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setFromString(fv, val); err != nil {
+			return fmt.Errorf("conf: env %s: %w", key, err)
+		}
+		if origins != nil {
+			origins[kp] = "env:" + key
+		}
+	}
+	return nil
+}
+
+// envFieldName 取字段对应的环境变量片段, toml标签优先, "-" 表示跳过
+func envFieldName(field reflect.StructField) string {
+	name := field.Name
+	if tag, ok := field.Tag.Lookup("toml"); ok {
+		tag = strings.Split(tag, ",")[0]
+		if tag == "-" {
+			return ""
+		}
+		if tag != "" {
+			name = tag
+		}
+	}
+	return strings.ToUpper(name)
+}
+
+// setFromString 把字符串解析为字段类型后赋值
+func setFromString(fv reflect.Value, val string) error {
+	if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		//逗号分隔
+		parts := strings.Split(val, ",")
+		s := reflect.MakeSlice(fv.Type(), 0, len(parts))
+		for _, p := range parts {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			item := reflect.New(fv.Type().Elem()).Elem()
+			if err := setFromString(item, p); err != nil {
+				return err
+			}
+			s = reflect.Append(s, item)
+		}
+		fv.Set(s)
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setFromString(fv.Elem(), val)
+	default:
+		return fmt.Errorf("unsupported kind %s", fv.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package conf
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EtcdSource 通过etcd v3的HTTP网关读取配置。
+// 前缀下的每个键对应一个配置项, 如 /gmf/Redis/Pwd, 值按配置字段的类型转换, 字符串字段保留原文。
+type EtcdSource struct {
+	Endpoints []string      //如 http://127.0.0.1:2379, 省略协议时使用http
+	Prefix    string        //键前缀, 如 /gmf/
+	User      string        //开启认证时的用户名
+	Password  string        //开启认证时的密码
+	Interval  time.Duration //轮询间隔, 默认5秒
+	Timeout   time.Duration //请求超时, 默认3秒
+	TLS       *tls.Config   //https节点的TLS设置, 如CA及客户端证书, 为nil时使用系统默认
+
+	clientOnce sync.Once
+	client     *http.Client
+	hosts      string
+}
+
+type etcdKV struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdRangeRes struct {
+	Kvs   []etcdKV `json:"kvs"`
+	Count string   `json:"count"`
+}
+
+// NewEtcdSource 创建etcd配置源, hosts为逗号分隔的地址, 与 Config.EtcdHost 格式相同
+func NewEtcdSource(hosts, prefix string) *EtcdSource {
+	s := &EtcdSource{Prefix: prefix, hosts: hosts}
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !strings.Contains(h, "://") {
+			h = "http://" + h
+		}
+		s.Endpoints = append(s.Endpoints, strings.TrimRight(h, "/"))
+	}
+	return s
+}
+
+func (s *EtcdSource) Name() string {
+	return "etcd:" + s.Prefix
+}
+
+// httpClient 第一次请求时按 Timeout 及 TLS 创建, 之后修改这两项不再生效
+func (s *EtcdSource) httpClient() *http.Client {
+	s.clientOnce.Do(func() {
+		timeout := s.Timeout
+		if timeout <= 0 {
+			timeout = 3 * time.Second
+		}
+		s.client = &http.Client{Timeout: timeout}
+		if s.TLS != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = s.TLS.Clone()
+			s.client.Transport = transport
+		}
+	})
+	return s.client
+}
+
+// post 依次尝试各个节点, 返回第一个成功的响应
+func (s *EtcdSource) post(api string, req interface{}, res interface{}) error {
+	if len(s.Endpoints) == 0 {
+		return errors.New("etcd: no endpoints")
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, ep := range s.Endpoints {
+		lastErr = s.postOne(ep, api, body, res)
+		if lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (s *EtcdSource) postOne(endpoint, api string, body []byte, res interface{}) error {
+	hreq, err := http.NewRequest(http.MethodPost, endpoint+api, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if s.User != "" && api != "/v3/auth/authenticate" {
+		token, err := s.authenticate(endpoint)
+		if err != nil {
+			return err
+		}
+		hreq.Header.Set("Authorization", token)
+	}
+	resp, err := s.httpClient().Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd: %s %s: %s", endpoint+api, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, res)
+}
+
+func (s *EtcdSource) authenticate(endpoint string) (string, error) {
+	body, _ := json.Marshal(map[string]string{"name": s.User, "password": s.Password})
+	res := struct {
+		Token string `json:"token"`
+	}{}
+	if err := s.postOne(endpoint, "/v3/auth/authenticate", body, &res); err != nil {
+		return "", err
+	}
+	return res.Token, nil
+}
+
+// rangeEnd 前缀查询的结束键: 前缀最后一个字节加一
+func rangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
+
+func (s *EtcdSource) rangePrefix() (*etcdRangeRes, error) {
+	req := map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(s.Prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(rangeEnd(s.Prefix)),
+	}
+	res := &etcdRangeRes{}
+	if err := s.post("/v3/kv/range", req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *EtcdSource) Read() (map[string]interface{}, error) {
+	res, err := s.rangePrefix()
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]interface{})
+	for _, kv := range res.Kvs {
+		key, err := base64.StdEncoding.DecodeString(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		path := strings.Trim(strings.TrimPrefix(string(key), s.Prefix), "/")
+		if path == "" {
+			continue
+		}
+		setTreePath(tree, strings.Split(path, "/"), string(val))
+	}
+	return tree, nil
+}
+
+// revision 前缀下所有键的最大修改版本及数量, 任一键增删改都会改变结果
+func (s *EtcdSource) revision() (string, error) {
+	res, err := s.rangePrefix()
+	if err != nil {
+		return "", err
+	}
+	var max int64
+	for _, kv := range res.Kvs {
+		if n, _ := strconv.ParseInt(kv.ModRevision, 10, 64); n > max {
+			max = n
+		}
+	}
+	return fmt.Sprintf("%d/%d", max, len(res.Kvs)), nil
+}
+
+func (s *EtcdSource) Watch(stop <-chan struct{}, notify func()) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	last, err := s.revision()
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+		rev, err := s.revision()
+		if err != nil {
+			return err
+		}
+		if rev != last {
+			last = rev
+			notify()
+		}
+	}
+}
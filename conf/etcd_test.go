@@ -0,0 +1,99 @@
+package conf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// newEtcdGateway 模拟开启认证的etcd v3 HTTPS网关, 只返回kvs中的键值
+func newEtcdGateway(t *testing.T, kvs map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["name"] != "gmf" || req["password"] != "secret" {
+				http.Error(w, `{"error":"authentication failed"}`, http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "tok"})
+		case "/v3/kv/range":
+			if r.Header.Get("Authorization") != "tok" {
+				http.Error(w, `{"error":"user name is empty"}`, http.StatusUnauthorized)
+				return
+			}
+			res := etcdRangeRes{}
+			for k, v := range kvs {
+				res.Kvs = append(res.Kvs, etcdKV{
+					Key:         base64.StdEncoding.EncodeToString([]byte(k)),
+					Value:       base64.StdEncoding.EncodeToString([]byte(v)),
+					ModRevision: "1",
+				})
+			}
+			json.NewEncoder(w).Encode(res)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEtcdSourceAuthTLS(t *testing.T) {
+	srv := newEtcdGateway(t, map[string]string{"/gmf/Redis/Pwd": "from-etcd"})
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	t.Setenv(EtcdUserEnv, "gmf")
+	t.Setenv(EtcdPasswordEnv, "secret")
+	t.Cleanup(StopWatch)
+
+	err := Load(Options{
+		FS:         fstest.MapFS{"config.toml": {Data: []byte("EtcdHost = \"" + srv.URL + "\"\n" + testConfig)}},
+		Path:       "config.toml",
+		Profiles:   []string{},
+		EtcdPrefix: "/gmf/",
+		EtcdTLS:    &tls.Config{RootCAs: pool},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pwd := Default().Redis.Pwd; pwd != "from-etcd" {
+		t.Fatalf("got %q", pwd)
+	}
+	if o := Origin("Redis.Pwd"); !strings.HasPrefix(o, "etcd:") {
+		t.Fatalf("origin: %q", o)
+	}
+}
+
+func TestEtcdSourceConcurrentRead(t *testing.T) {
+	srv := newEtcdGateway(t, map[string]string{"/gmf/Port": "9090"})
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	s := NewEtcdSource(srv.URL, "/gmf/")
+	s.User, s.Password, s.TLS = "gmf", "secret", &tls.Config{RootCAs: pool}
+
+	//轮询协程与 Load / Reload 会同时使用同一个配置源
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Read(); err != nil {
+				t.Error(err)
+			}
+			if _, err := s.revision(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
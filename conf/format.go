@@ -0,0 +1,82 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 支持的配置文件扩展名, 按查找优先级排列
+var configExts = []string{".toml", ".yaml", ".yml", ".json"}
+
+// decodeFormat 按扩展名把配置文件解码为通用的树, 之后统一按toml的字段映射规则解码到结构体,
+// 所以 Config 的字段名及 AlyConf 的toml标签在三种格式下写法一致。
+func decodeFormat(name string, data []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return nil, err
+		}
+	case ".toml", "":
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", path.Ext(name))
+	}
+	return normalizeTree(m), nil
+}
+
+// normalizeTree 把yaml/json解码结果转成toml可编码的值: 去掉null, 统一map键为字符串, json数字转为整数或浮点数
+func normalizeTree(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		nv := normalizeValue(v)
+		if nv == nil {
+			delete(m, k)
+			continue
+		}
+		m[k] = nv
+	}
+	return m
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return normalizeTree(t)
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			m[fmt.Sprint(k)] = item
+		}
+		return normalizeTree(m)
+	case []interface{}:
+		res := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			if nv := normalizeValue(item); nv != nil {
+				res = append(res, nv)
+			}
+		}
+		return res
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package conf
+
+import (
+	"crypto/tls"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/wyy8261/gmf/logger"
+)
+
+// ConfigName 默认配置文件名(不含扩展名), 按 .toml/.yaml/.yml/.json 顺序查找
+const ConfigName = "config"
+
+// Options 配置加载参数
+type Options struct {
+	Path         string      //配置文件路径, 按扩展名识别格式, 为空时依次在SearchPaths中查找ConfigName
+	SearchPaths  []string    //查找目录, 为空时使用 DefaultSearchPaths()
+	Chdir        bool        //加载前把工作目录切换到可执行文件所在目录
+	FS           fs.FS       //非nil时从FS读取配置文件(如embed.FS), 路径为FS内的路径
+	Profiles     []string    //为nil时取 Profile()
+	FailFast     bool        //加载或校验失败时直接退出进程, 环境变量 GMF_CONF_FAILFAST 同样生效
+	Sources      []Source    //远程配置源, 按顺序叠加在本地配置之上
+	EtcdPrefix   string      //非空时按本地配置的 EtcdHost 从etcd读取该前缀下的配置
+	EtcdUser     string      //etcd开启认证时的用户名, 为空时取环境变量 GMF_ETCD_USER
+	EtcdPassword string      //etcd密码, EtcdUser 为空时取环境变量 GMF_ETCD_PASSWORD
+	EtcdTLS      *tls.Config //etcd使用https时的TLS设置, 如CA及客户端证书
+}
+
+var (
+	gOpts   Options
+	gLazyMu sync.Mutex
+)
+
+// DefaultSearchPaths 默认查找目录: 工作目录下的conf, 工作目录, 可执行文件目录下的conf, 可执行文件目录
+func DefaultSearchPaths() []string {
+	paths := []string{"conf", "."}
+	if dir, err := execDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "conf"), dir)
+	}
+	return paths
+}
+
+func execDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exe), nil
+}
+
+// Load 按opts加载配置, 成功或失败都会替换 Default() 返回的配置,
+// 返回值汇总了文件缺失、格式错误及校验失败。
+func Load(opts Options) error {
+	if opts.Chdir {
+		dir, err := execDir()
+		if err != nil {
+			return err
+		}
+		//切换执行路径
+		if err = os.Chdir(dir); err != nil {
+			return err
+		}
+		logger.LOGD("Chdir:", dir)
+	}
+
+	gReloadMu.Lock()
+	gOpts = opts
+	gPath = resolvePath(&opts)
+	st, err := loadState(gPath)
+	st.err = joinLoadErr(err, st.cfg.Validate())
+	old := gState.Load()
+	gState.Store(st)
+	resetSections()
+	gReloadMu.Unlock()
+
+	applyLog(st.cfg)
+	logger.LOGD("config files:", st.files)
+	if st.err != nil {
+		logger.LOGE("err:", st.err)
+		if opts.FailFast || failFast() {
+			//退出前写完队列中的日志
+			logger.Close()
+			os.Exit(1)
+		}
+	}
+	if old != nil && st.err == nil {
+		notify(old.cfg, st.cfg)
+	}
+	return st.err
+}
+
+// resolvePath 解析配置文件路径, 未找到时返回第一个候选路径以便报错信息可读
+func resolvePath(opts *Options) string {
+	if opts.Path != "" {
+		return opts.Path
+	}
+	dirs := opts.SearchPaths
+	if len(dirs) == 0 {
+		dirs = DefaultSearchPaths()
+		if opts.FS != nil {
+			dirs = []string{"conf", "."}
+		}
+	}
+	for _, dir := range dirs {
+		for _, ext := range configExts {
+			f := joinPath(opts.FS, dir, ConfigName+ext)
+			if existIn(opts.FS, f) {
+				return f
+			}
+		}
+	}
+	return joinPath(opts.FS, dirs[0], ConfigName+configExts[0])
+}
+
+func joinPath(fsys fs.FS, dir, name string) string {
+	if fsys != nil {
+		return path.Join(dir, name)
+	}
+	return filepath.Join(dir, name)
+}
+
+func existIn(fsys fs.FS, f string) bool {
+	if fsys == nil {
+		return IsExist(f)
+	}
+	_, err := fs.Stat(fsys, f)
+	return err == nil
+}
+
+func readFile(fsys fs.FS, f string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(f)
+	}
+	return fs.ReadFile(fsys, f)
+}
+
+func statFile(fsys fs.FS, f string) (fs.FileInfo, error) {
+	if fsys == nil {
+		return os.Stat(f)
+	}
+	return fs.Stat(fsys, f)
+}
+
+// current 返回当前配置快照, 未显式调用 Load 时第一次访问按默认参数加载
+func current() *state {
+	if st := gState.Load(); st != nil {
+		return st
+	}
+	gLazyMu.Lock()
+	defer gLazyMu.Unlock()
+	if gState.Load() == nil {
+		Load(Options{})
+	}
+	return gState.Load()
+}
@@ -0,0 +1,151 @@
+package conf
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// ProfileEnv 选择配置profile的环境变量, 多个profile用逗号分隔, 按顺序叠加
+	ProfileEnv = "GMF_PROFILE"
+	// ProfileFlag 选择配置profile的命令行参数, 如 -conf.profile=prod
+	ProfileFlag = "conf.profile"
+)
+
+// gProfileFlag 注册在 flag.CommandLine 中, 程序调用 flag.Parse() 时一并解析
+var gProfileFlag = flag.String(ProfileFlag, "", "config profiles, comma separated, overrides $"+ProfileEnv)
+
+// Profile 返回当前生效的profile列表, 命令行参数优先于环境变量。
+// 命令行参数在 flag.Parse() 之后才生效, 需要在 Load 之前解析
+func Profile() []string {
+	val := *gProfileFlag
+	if val == "" {
+		val = os.Getenv(ProfileEnv)
+	}
+	res := make([]string, 0)
+	for _, p := range strings.Split(val, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// profileFiles 返回基础配置及其profile叠加文件, 如 config.toml, config.prod.toml。
+// profile文件优先使用与基础配置相同的格式, 不存在时再按其它格式查找。
+func profileFiles(fsys fs.FS, base string, profiles []string) []string {
+	files := []string{base}
+	ext := path.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+	exts := append([]string{ext}, configExts...)
+	for _, p := range profiles {
+		for _, e := range exts {
+			f := prefix + "." + p + e
+			if existIn(fsys, f) {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// loadTree 依次解码files并深度合并, 后面的文件覆盖前面的值。
+// origins 记录每个叶子键(小写, 以点分隔)最终取值所在的文件。
+func loadTree(fsys fs.FS, files []string) (map[string]interface{}, map[string]string, error) {
+	tree := make(map[string]interface{})
+	origins := make(map[string]string)
+	for _, f := range files {
+		data, err := readFile(fsys, f)
+		if err != nil {
+			return tree, origins, err
+		}
+		m, err := decodeFormat(f, data)
+		if err != nil {
+			return tree, origins, fmt.Errorf("%s: %w", f, err)
+		}
+		mergeTree(tree, m, "", f, origins)
+	}
+	return tree, origins, nil
+}
+
+// mergeTree 把src深度合并进dst, 表按键递归合并, 其余值直接覆盖
+func mergeTree(dst, src map[string]interface{}, prefix, origin string, origins map[string]string) {
+	for k, v := range src {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		sm, ok := v.(map[string]interface{})
+		if ok {
+			dk := findKey(dst, k)
+			dm, ok := dst[dk].(map[string]interface{})
+			if !ok {
+				dm = make(map[string]interface{})
+				delete(dst, dk)
+				dst[k] = dm
+			}
+			mergeTree(dm, sm, key, origin, origins)
+			continue
+		}
+		if dk := findKey(dst, k); dk != k {
+			delete(dst, dk)
+		}
+		dst[k] = v
+		for ko := range origins {
+			//覆盖整张表时清除旧的子键来源
+			if strings.HasPrefix(ko, key+".") {
+				delete(origins, ko)
+			}
+		}
+		origins[key] = origin
+	}
+}
+
+// findKey 忽略大小写查找已存在的键, 与toml解码到结构体时的匹配规则一致
+func findKey(m map[string]interface{}, k string) string {
+	if _, ok := m[k]; ok {
+		return k
+	}
+	for mk := range m {
+		if strings.EqualFold(mk, k) {
+			return mk
+		}
+	}
+	return k
+}
+
+// decodeTree 把合并后的树解码到v, 字段映射规则与直接解码toml文件相同
+func decodeTree(tree map[string]interface{}, v interface{}) error {
+	var bf bytes.Buffer
+	if err := toml.NewEncoder(&bf).Encode(tree); err != nil {
+		return err
+	}
+	_, err := toml.Decode(bf.String(), v)
+	return err
+}
+
+// Origin 返回配置项最终取值的来源(文件路径或 env:变量名), key 如 "Redis.Pwd", 忽略大小写
+func Origin(key string) string {
+	return current().origins[strings.ToLower(key)]
+}
+
+// Origins 返回所有配置项的来源, 按键排序后的 "key=来源" 列表
+func Origins() []string {
+	origins := current().origins
+	res := make([]string, 0, len(origins))
+	for k, v := range origins {
+		res = append(res, k+"="+v)
+	}
+	sort.Strings(res)
+	return res
+}
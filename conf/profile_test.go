@@ -0,0 +1,28 @@
+package conf
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestProfileFlag(t *testing.T) {
+	//注册在 flag.CommandLine 中, 程序自己的 flag.Parse() 不会报未定义的参数
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	t.Cleanup(func() { *gProfileFlag = "" })
+
+	t.Setenv(ProfileEnv, "test")
+	if got := Profile(); !reflect.DeepEqual(got, []string{"test"}) {
+		t.Fatalf("env: got %v", got)
+	}
+	if err := fs.Parse([]string{"-" + ProfileFlag + "=prod, local", "-v"}); err == nil {
+		t.Fatal("undefined flag -v accepted")
+	}
+	if err := fs.Parse([]string{"-" + ProfileFlag, "prod, local", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := Profile(); !reflect.DeepEqual(got, []string{"prod", "local"}) {
+		t.Fatalf("flag: got %v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package conf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const (
+	// SecretPrefix 加密配置值的前缀, 如 Pwd = "enc:xxxx"
+	SecretPrefix = "enc:"
+	// MasterKeyEnv 主密钥环境变量
+	MasterKeyEnv = "GMF_MASTER_KEY"
+	// MasterKeyFileEnv 主密钥文件路径环境变量, 未设置时使用 DefaultMasterKeyFile
+	MasterKeyFileEnv = "GMF_MASTER_KEY_FILE"
+	// DefaultMasterKeyFile 默认主密钥文件
+	DefaultMasterKeyFile = "conf/master.key"
+)
+
+var ErrNoMasterKey = errors.New("conf: master key not found, set " + MasterKeyEnv + " or " + MasterKeyFileEnv)
+
+// MasterKey 读取主密钥, 环境变量优先于密钥文件
+func MasterKey() ([]byte, error) {
+	if key := os.Getenv(MasterKeyEnv); key != "" {
+		return []byte(key), nil
+	}
+	f := os.Getenv(MasterKeyFileEnv)
+	if f == "" {
+		f = DefaultMasterKeyFile
+	}
+	data, err := os.ReadFile(f)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoMasterKey
+		}
+		return nil, err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, ErrNoMasterKey
+	}
+	return []byte(key), nil
+}
+
+func secretCipher(masterKey []byte) (cipher.AEAD, error) {
+	if len(masterKey) == 0 {
+		return nil, ErrNoMasterKey
+	}
+	//任意长度的主密钥经sha256派生为AES-256密钥
+	sum := sha256.Sum256(masterKey)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret 用主密钥加密明文, 返回可直接写入配置文件的 "enc:..." 字符串
+func EncryptSecret(plain string, masterKey []byte) (string, error) {
+	aead, err := secretCipher(masterKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return SecretPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecryptSecret 解密 "enc:..." 字符串, 非加密值原样返回
+func DecryptSecret(val string, masterKey []byte) (string, error) {
+	if !IsSecret(val) {
+		return val, nil
+	}
+	aead, err := secretCipher(masterKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(val, SecretPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("conf: secret too short")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("conf: secret decrypt failed, wrong master key?")
+	}
+	return string(plain), nil
+}
+
+// IsSecret 判断配置值是否为加密值
+func IsSecret(val string) bool {
+	return strings.HasPrefix(val, SecretPrefix)
+}
+
+// decryptSecrets 解密v(结构体指针)中所有 "enc:" 开头的字符串, 仅在存在加密值时读取主密钥
+func decryptSecrets(v interface{}) error {
+	var (
+		key  []byte
+		errs []error
+	)
+	walkStrings(reflect.ValueOf(v), "", func(field string, sv reflect.Value) {
+		if !IsSecret(sv.String()) {
+			return
+		}
+		if key == nil {
+			var err error
+			if key, err = MasterKey(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field, err))
+				return
+			}
+		}
+		plain, err := DecryptSecret(sv.String(), key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+			return
+		}
+		sv.SetString(plain)
+	})
+	return errors.Join(errs...)
+}
+
+// walkStrings 遍历所有可写的字符串值
+func walkStrings(rv reflect.Value, field string, fn func(field string, sv reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !rv.IsNil() {
+			walkStrings(rv.Elem(), field, fn)
+		}
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if !rt.Field(i).IsExported() {
+				continue
+			}
+			name := rt.Field(i).Name
+			if field != "" {
+				name = field + "." + name
+			}
+			walkStrings(rv.Field(i), name, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			walkStrings(rv.Index(i), fmt.Sprintf("%s[%d]", field, i), fn)
+		}
+	case reflect.Map:
+		//map元素不可寻址, 拷贝后写回
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			walkStrings(elem, fmt.Sprintf("%s[%v]", field, iter.Key()), fn)
+			rv.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.String:
+		if rv.CanSet() {
+			fn(field, rv)
+		}
+	}
+}
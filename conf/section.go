@@ -0,0 +1,140 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSectionNotFound = errors.New("conf: section not found")
+
+// sectionKey 缓存键, 同一个表可以解码为不同类型
+type sectionKey struct {
+	key string
+	typ reflect.Type
+}
+
+// sectionVal 缓存值, 记录解码时的配置快照, 快照已被替换的缓存视为无效
+type sectionVal struct {
+	st *state
+	v  interface{}
+}
+
+var gSections sync.Map
+
+// Section 把配置中名为key的表(如 [myservice], 嵌套表用点分隔 "myservice.db")解码为T。
+// 结果按类型和key缓存, 配置热加载后重新解码; 字段可用 default 标签声明默认值,
+// 环境变量前缀为 GMF_<KEY>, 如 GMF_MYSERVICE_TIMEOUT。
+func Section[T any](key string) (*T, error) {
+	ck := sectionKey{key: strings.ToLower(key), typ: reflect.TypeOf((*T)(nil)).Elem()}
+	st := current()
+	if v, ok := gSections.Load(ck); ok && v.(*sectionVal).st == st {
+		return v.(*sectionVal).v.(*T), nil
+	}
+
+	if st.tree == nil {
+		return nil, fmt.Errorf("conf: section %q: %w", key, st.err)
+	}
+	tree := st.tree
+	if key != "" {
+		sub, ok := subTree(st.tree, key)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrSectionNotFound, key)
+		}
+		tree = sub
+	}
+
+	res := new(T)
+	rv := reflect.ValueOf(res).Elem()
+	isStruct := rv.Kind() == reflect.Struct
+	if isStruct {
+		if err := applyDefaults(rv); err != nil {
+			return nil, fmt.Errorf("conf: section %q: %w", key, err)
+		}
+	}
+	if err := decodeTree(tree, res); err != nil {
+		return nil, fmt.Errorf("conf: section %q: %w", key, err)
+	}
+	if isStruct {
+		prefix := EnvPrefix
+		if key != "" {
+			prefix += "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		}
+		if err := ApplyEnv(prefix, res); err != nil {
+			return nil, err
+		}
+	}
+	if err := decryptSecrets(res); err != nil {
+		return nil, fmt.Errorf("conf: section %q: %w", key, err)
+	}
+	return storeSection(ck, &sectionVal{st: st, v: res}).(*T), nil
+}
+
+// storeSection 存入缓存并返回应使用的结果: 并发首次访问同一快照时以先存入的为准;
+// 解码期间配置已被替换时不存入, 避免 resetSections 之后又存入旧配置的结果
+func storeSection(ck sectionKey, sv *sectionVal) interface{} {
+	for {
+		old, loaded := gSections.LoadOrStore(ck, sv)
+		if !loaded {
+			return sv.v
+		}
+		if old.(*sectionVal).st == sv.st {
+			return old.(*sectionVal).v
+		}
+		if gState.Load() != sv.st {
+			return sv.v
+		}
+		if gSections.CompareAndSwap(ck, old, sv) {
+			return sv.v
+		}
+	}
+}
+
+// subTree 按点分隔的路径查找子表, 忽略大小写
+func subTree(tree map[string]interface{}, key string) (map[string]interface{}, bool) {
+	for _, k := range strings.Split(key, ".") {
+		sub, ok := tree[findKey(tree, k)].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		tree = sub
+	}
+	return tree, true
+}
+
+// applyDefaults 按 default 标签给字段设置默认值, 嵌套结构体递归处理
+func applyDefaults(rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			continue
+		}
+		if err := setFromString(fv, def); err != nil {
+			return fmt.Errorf("%s default %q: %w", field.Name, def, err)
+		}
+	}
+	return nil
+}
+
+// resetSections 配置替换后清除缓存
+func resetSections() {
+	gSections.Range(func(k, v interface{}) bool {
+		gSections.Delete(k)
+		return true
+	})
+}
@@ -0,0 +1,46 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+type redisSection struct {
+	Pwd string
+}
+
+func TestSectionStaleCache(t *testing.T) {
+	src := NewMemorySource("section")
+	src.Set("Redis.Pwd", "v1")
+	if err := loadWith(t, src); err != nil {
+		t.Fatal(err)
+	}
+	old := current()
+	if r, err := Section[redisSection]("Redis"); err != nil || r.Pwd != "v1" {
+		t.Fatalf("got %+v, %v", r, err)
+	}
+
+	src.Set("Redis.Pwd", "v2")
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	//模拟解码旧配置的协程在 resetSections 之后才存入缓存
+	ck := sectionKey{key: "redis", typ: reflect.TypeOf(redisSection{})}
+	if v := storeSection(ck, &sectionVal{st: old, v: &redisSection{Pwd: "v1"}}); v.(*redisSection).Pwd != "v1" {
+		t.Fatalf("stale caller got %+v", v)
+	}
+	if r, err := Section[redisSection]("Redis"); err != nil || r.Pwd != "v2" {
+		t.Fatalf("after reload got %+v, %v", r, err)
+	}
+
+	//旧快照的缓存即使已存入也不会被返回
+	gSections.Store(ck, &sectionVal{st: old, v: &redisSection{Pwd: "v1"}})
+	if r, err := Section[redisSection]("Redis"); err != nil || r.Pwd != "v2" {
+		t.Fatalf("stale entry returned %+v, %v", r, err)
+	}
+	a, _ := Section[redisSection]("Redis")
+	b, _ := Section[redisSection]("Redis")
+	if a != b {
+		t.Fatal("cache miss on the same snapshot")
+	}
+}
@@ -0,0 +1,313 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/wyy8261/gmf/logger"
+)
+
+// Source 远程配置源, 读取的配置树叠加在本地配置文件之上
+type Source interface {
+	// Name 配置来源名称, 用于 Origin 及日志
+	Name() string
+	// Read 读取完整的配置树, 键为各级字段名, 如 {"Redis": {"Pwd": "xx"}};
+	// 字符串值按配置字段的类型转换, 如 {"Redis": {"Port": "6379"}}
+	Read() (map[string]interface{}, error)
+	// Watch 阻塞监听配置变化, 变化时调用notify, stop关闭后返回
+	Watch(stop <-chan struct{}, notify func()) error
+}
+
+const sourceRetryInterval = 5 * time.Second
+
+const (
+	// EtcdUserEnv etcd用户名环境变量, Options.EtcdUser 为空时使用
+	EtcdUserEnv = "GMF_ETCD_USER"
+	// EtcdPasswordEnv etcd密码环境变量, Options.EtcdPassword 为空时使用
+	EtcdPasswordEnv = "GMF_ETCD_PASSWORD"
+)
+
+var (
+	gRemoteMutex sync.Mutex
+	gRemoteLast  = make(map[Source]map[string]interface{})
+	gEtcdSource  *EtcdSource
+)
+
+// sources 返回本次加载使用的配置源: Options.Sources, 以及设置了 Options.EtcdPrefix 时
+// 按本地配置(含环境变量)中的 EtcdHost 创建的etcd配置源
+func sources(local map[string]interface{}) []Source {
+	res := append([]Source{}, gOpts.Sources...)
+	if gOpts.EtcdPrefix == "" {
+		return res
+	}
+	c := &Config{}
+	decodeTree(local, c)
+	ApplyEnv(EnvPrefix, c)
+	if c.EtcdHost == "" {
+		return res
+	}
+	user, pwd := gOpts.EtcdUser, gOpts.EtcdPassword
+	if user == "" {
+		user, pwd = os.Getenv(EtcdUserEnv), os.Getenv(EtcdPasswordEnv)
+	}
+	gRemoteMutex.Lock()
+	defer gRemoteMutex.Unlock()
+	//地址及认证不变时复用, 以便不可达时使用上一次的结果
+	s := gEtcdSource
+	if s == nil || s.hosts != c.EtcdHost || s.Prefix != gOpts.EtcdPrefix || s.User != user || s.Password != pwd || s.TLS != gOpts.EtcdTLS {
+		s = NewEtcdSource(c.EtcdHost, gOpts.EtcdPrefix)
+		s.User, s.Password, s.TLS = user, pwd, gOpts.EtcdTLS
+		gEtcdSource = s
+	}
+	return append(res, s)
+}
+
+// readSource 读取远程配置, 不可达时使用上一次成功读取的结果, 从未成功过则只用本地配置
+func readSource(src Source) map[string]interface{} {
+	tree, err := src.Read()
+	gRemoteMutex.Lock()
+	defer gRemoteMutex.Unlock()
+	if err != nil {
+		last := gRemoteLast[src]
+		if last != nil {
+			logger.LOGW("source:", src.Name(), " unreachable, using last read, err:", err)
+		} else {
+			logger.LOGW("source:", src.Name(), " unreachable, using local config, err:", err)
+		}
+		return last
+	}
+	gRemoteLast[src] = tree
+	return tree
+}
+
+// watchSource 监听远程配置, 出错后间隔重试
+func watchSource(src Source, stop chan struct{}) {
+	for {
+		err := src.Watch(stop, func() {
+			if err := Reload(); err != nil {
+				logger.LOGE("reload from:", src.Name(), ", err:", err)
+				return
+			}
+			logger.LOGI("reload from:", src.Name())
+		})
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err != nil {
+			logger.LOGE("watch:", src.Name(), ", err:", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(sourceRetryInterval):
+		}
+	}
+}
+
+// setTreePath 按路径把值写入配置树
+func setTreePath(tree map[string]interface{}, keys []string, val interface{}) {
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := tree[k].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			tree[k] = sub
+		}
+		tree = sub
+	}
+	tree[keys[len(keys)-1]] = val
+}
+
+// parseValue 把远程配置的字符串值按toml字面量解析, 如 6379, true, ["a","b"], 解析失败按普通字符串处理
+func parseValue(s string) interface{} {
+	var v struct{ V interface{} }
+	if _, err := toml.Decode("V = "+s, &v); err == nil && v.V != nil {
+		return v.V
+	}
+	return s
+}
+
+var gServerInfoType = reflect.TypeOf(ServerInfo{})
+
+// typedTree 按配置结构体字段的类型转换远程配置树中的值: 字符串字段保留原文, 其他字段按toml字面量解析。
+// 无法转换为字段类型的值记录警告后忽略, 保留本地配置, 不影响其他配置项; 结构体中没有的键按 parseValue 处理
+func typedTree(tree map[string]interface{}, t reflect.Type, prefix, origin string) map[string]interface{} {
+	res := make(map[string]interface{}, len(tree))
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		ft, known := fieldType(t, k)
+		//具名连接块, 如 Mssql.log
+		if !known && t == gServerInfoType {
+			if _, ok := v.(map[string]interface{}); ok {
+				ft, known = gServerInfoType, true
+			}
+		}
+		if !known {
+			res[k] = untypedValue(v)
+			continue
+		}
+		if sub, ok := v.(map[string]interface{}); ok {
+			switch ft.Kind() {
+			case reflect.Struct, reflect.Map:
+				res[k] = typedTree(sub, ft, key, origin)
+				continue
+			}
+		}
+		val, err := typedValue(v, ft)
+		if err != nil {
+			logger.LOGW("source:", origin, ", key:", key, ", invalid value ignored, err:", err)
+			continue
+		}
+		res[k] = val
+	}
+	return res
+}
+
+// fieldType 键对应的字段类型, t为map时为元素类型; 字段名忽略大小写, 有toml标签时按标签匹配
+func fieldType(t reflect.Type, key string) (reflect.Type, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag := strings.Split(f.Tag.Get("toml"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			if strings.EqualFold(name, key) {
+				return f.Type, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// typedValue 把值转换为字段类型可以解码的值
+func typedValue(v interface{}, ft reflect.Type) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		if ft.Kind() == reflect.String {
+			return s, nil
+		}
+		if ft != reflect.TypeOf(time.Duration(0)) {
+			v = parseValue(s)
+		}
+	} else if ft.Kind() == reflect.String {
+		if _, ok := v.(map[string]interface{}); !ok {
+			v = fmt.Sprint(v)
+		}
+	}
+	//按字段类型试解码一次, 与最终解码配置的规则一致
+	dst := reflect.New(reflect.StructOf([]reflect.StructField{{Name: "V", Type: ft}}))
+	if err := decodeTree(map[string]interface{}{"V": v}, dst.Interface()); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func untypedValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case string:
+		return parseValue(tv)
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(tv))
+		for k, sv := range tv {
+			res[k] = untypedValue(sv)
+		}
+		return res
+	}
+	return v
+}
+
+// MemorySource 内存配置源, 用于测试及在进程内下发配置
+type MemorySource struct {
+	name   string
+	mutex  sync.Mutex
+	values map[string]interface{}
+	err    error
+	rouse  chan struct{}
+}
+
+// NewMemorySource 创建内存配置源
+func NewMemorySource(name string) *MemorySource {
+	return &MemorySource{
+		name:   name,
+		values: make(map[string]interface{}),
+		rouse:  make(chan struct{}, 1),
+	}
+}
+
+func (m *MemorySource) Name() string {
+	return "memory:" + m.name
+}
+
+// Set 设置配置项, key为点分隔的字段路径, 如 "Redis.Pwd"
+func (m *MemorySource) Set(key string, val interface{}) {
+	m.mutex.Lock()
+	m.values[key] = val
+	m.mutex.Unlock()
+	m.changed()
+}
+
+// Delete 删除配置项
+func (m *MemorySource) Delete(key string) {
+	m.mutex.Lock()
+	delete(m.values, key)
+	m.mutex.Unlock()
+	m.changed()
+}
+
+// SetError 设置后 Read 返回该错误, 用于模拟配置中心不可达, nil 恢复
+func (m *MemorySource) SetError(err error) {
+	m.mutex.Lock()
+	m.err = err
+	m.mutex.Unlock()
+}
+
+func (m *MemorySource) changed() {
+	select {
+	case m.rouse <- struct{}{}:
+	default:
+	}
+}
+
+func (m *MemorySource) Read() (map[string]interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	tree := make(map[string]interface{})
+	for k, v := range m.values {
+		setTreePath(tree, strings.Split(k, "."), v)
+	}
+	return tree, nil
+}
+
+func (m *MemorySource) Watch(stop <-chan struct{}, notify func()) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-m.rouse:
+			notify()
+		}
+	}
+}
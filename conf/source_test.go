@@ -0,0 +1,137 @@
+package conf
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const testConfig = `
+IP = "127.0.0.1"
+Port = 8080
+
+[Redis]
+IP = "10.0.0.1"
+Port = 6379
+Pwd = "local"
+`
+
+// loadWith 从内存文件系统加载testConfig, 叠加sources
+func loadWith(t *testing.T, sources ...Source) error {
+	t.Helper()
+	t.Cleanup(StopWatch)
+	return Load(Options{
+		FS:       fstest.MapFS{"config.toml": {Data: []byte(testConfig)}},
+		Path:     "config.toml",
+		Profiles: []string{},
+		Sources:  sources,
+	})
+}
+
+func TestSourceOverlayOrder(t *testing.T) {
+	first := NewMemorySource("first")
+	first.Set("Redis.Pwd", "first")
+	first.Set("Redis.DBName", "first")
+	second := NewMemorySource("second")
+	second.Set("Redis.Pwd", "second")
+
+	if err := loadWith(t, first, second); err != nil {
+		t.Fatal(err)
+	}
+	c := Default()
+	if c.Redis.Pwd != "second" || c.Redis.DBName != "first" || c.Redis.IP != "10.0.0.1" {
+		t.Fatalf("got %+v", c.Redis)
+	}
+	if o := Origin("Redis.Pwd"); o != second.Name() {
+		t.Fatalf("origin: %q", o)
+	}
+}
+
+func TestSourceTypedValues(t *testing.T) {
+	src := NewMemorySource("typed")
+	src.Set("Redis.Pwd", "123456")
+	src.Set("Redis.Port", "6380")
+	src.Set("Redis.User", "true")
+	src.Set("Mssql.Port", "not a number")
+	src.Set("Log.MaxAge", "24h")
+
+	if err := loadWith(t, src); err != nil {
+		t.Fatal(err)
+	}
+	c := Default()
+	if c.Redis.Pwd != "123456" || c.Redis.Port != 6380 || c.Redis.User != "true" || c.Redis.IP != "10.0.0.1" {
+		t.Fatalf("got %+v", c.Redis)
+	}
+	//无法转换的值被忽略, 不影响其他配置项
+	if c.Mssql.Port != 0 || c.Port != 8080 {
+		t.Fatalf("got mssql port %d, port %d", c.Mssql.Port, c.Port)
+	}
+	if c.Log.MaxAge != 24*time.Hour {
+		t.Fatalf("got max age %v", c.Log.MaxAge)
+	}
+}
+
+func TestSourceFallback(t *testing.T) {
+	src := NewMemorySource("fallback")
+	src.SetError(errors.New("unreachable"))
+	src.Set("Redis.Pwd", "remote")
+
+	//从未读取成功时使用本地配置
+	if err := loadWith(t, src); err != nil {
+		t.Fatal(err)
+	}
+	if pwd := Default().Redis.Pwd; pwd != "local" {
+		t.Fatalf("never read: got %q", pwd)
+	}
+
+	src.SetError(nil)
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if pwd := Default().Redis.Pwd; pwd != "remote" {
+		t.Fatalf("reachable: got %q", pwd)
+	}
+
+	//不可达时使用上一次读取的结果
+	src.SetError(errors.New("unreachable"))
+	src.Set("Redis.Pwd", "changed")
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if pwd := Default().Redis.Pwd; pwd != "remote" {
+		t.Fatalf("last read: got %q", pwd)
+	}
+}
+
+func TestSourceWatchReload(t *testing.T) {
+	src := NewMemorySource("watch")
+	src.Set("Redis.Pwd", "v1")
+	if err := loadWith(t, src); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan string, 1)
+	OnChange(func(old, new *Config) {
+		if new.Redis.Pwd == "v2" {
+			select {
+			case changed <- old.Redis.Pwd:
+			default:
+			}
+		}
+	})
+	Watch(time.Hour)
+	src.Set("Redis.Pwd", "v2")
+
+	select {
+	case old := <-changed:
+		if old != "v1" {
+			t.Fatalf("old: got %q", old)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no reload after source change")
+	}
+	if pwd := Default().Redis.Pwd; pwd != "v2" {
+		t.Fatalf("got %q", pwd)
+	}
+}
@@ -0,0 +1,244 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wyy8261/gmf/logger"
+)
+
+// FailFastEnv 为true时配置加载或校验失败直接退出进程
+const FailFastEnv = "GMF_CONF_FAILFAST"
+
+// Check 校验单个字段, field为字段路径, v为字段值
+type Check func(field string, v reflect.Value) error
+
+// Rule 声明式校验规则
+type Rule struct {
+	Field string               //字段路径, 如 "Redis.Port"
+	When  func(c *Config) bool //为nil时总是校验
+	Check Check
+}
+
+// ValidationError 汇总所有未通过的规则
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("conf: %d invalid field(s): %s", len(e.Errs), strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+var (
+	gRuleMutex sync.RWMutex
+	gRules     = defaultRules()
+)
+
+func defaultRules() []Rule {
+	rules := []Rule{
+		{Field: "Port", Check: IntRange(0, 65535)},
+		{Field: "Port", When: func(c *Config) bool { return c.IP != "" }, Check: PortRange()},
+		{Field: "AesKey", When: func(c *Config) bool { return c.AesKey != "" }, Check: LenIn(16, 24, 32)},
+		{Field: "AesKeys", Check: func(field string, v reflect.Value) error {
+			keys := v.Interface().(map[string]string)
+			ids := make([]string, 0, len(keys))
+			for id := range keys {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				if err := LenIn(16, 24, 32)(field+"."+id, reflect.ValueOf(keys[id])); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{Field: "Oss", When: func(c *Config) bool { return c.Oss != "" }, Check: OneOf("aly", "aws")},
+		{Field: "Log", Check: func(field string, v reflect.Value) error {
+			lc := v.Interface().(logger.Config)
+			if err := lc.Validate(); err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+			return nil
+		}},
+		{Field: "TLS.Key", When: func(c *Config) bool { return c.TLS.Cert != "" }, Check: Required()},
+		{Field: "TLS.Cert", When: func(c *Config) bool { return c.TLS.Key != "" }, Check: Required()},
+	}
+	isAly := func(c *Config) bool { return c.Oss == "aly" }
+	for _, f := range []string{"Aly.Endpoint", "Aly.AccessKeyId", "Aly.AccessKeySecret", "Aly.BucketName"} {
+		rules = append(rules, Rule{Field: f, When: isAly, Check: Required()})
+	}
+	//连接块只要填写了任一字段就必须有完整的地址
+	for _, name := range []string{"Redis", "Mssql", "RabbitMQ", "Mongo"} {
+		used := serverUsed(name)
+		rules = append(rules,
+			Rule{Field: name + ".IP", When: used, Check: Required()},
+			Rule{Field: name + ".Port", When: used, Check: PortRange()},
+		)
+	}
+	return rules
+}
+
+func serverUsed(name string) func(c *Config) bool {
+	return func(c *Config) bool {
+		v, ok := fieldByPath(reflect.ValueOf(c).Elem(), name)
+		return ok && !v.IsZero()
+	}
+}
+
+// AddRule 追加校验规则, 对之后的加载及热加载生效
+func AddRule(rules ...Rule) {
+	gRuleMutex.Lock()
+	gRules = append(gRules, rules...)
+	gRuleMutex.Unlock()
+}
+
+// Validate 按规则校验c, 返回汇总了所有错误的 *ValidationError
+func Validate(c *Config) error {
+	gRuleMutex.RLock()
+	rules := gRules
+	gRuleMutex.RUnlock()
+
+	var errs []error
+	rv := reflect.ValueOf(c).Elem()
+	for _, r := range rules {
+		if r.When != nil && !r.When(c) {
+			continue
+		}
+		v, ok := fieldByPath(rv, r.Field)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown field", r.Field))
+			continue
+		}
+		if err := r.Check(r.Field, v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, field := range namedFields(c) {
+		block, name, _ := strings.Cut(field, ".")
+		info := c.Named[block][name]
+		if err := Required()(field+".IP", reflect.ValueOf(info.IP)); err != nil {
+			errs = append(errs, err)
+		}
+		if err := PortRange()(field+".Port", reflect.ValueOf(info.Port)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errs: errs}
+	}
+	return nil
+}
+
+// namedFields 返回排序后的具名实例路径, 如 "Mssql.log"
+func namedFields(c *Config) []string {
+	res := make([]string, 0)
+	for block, m := range c.Named {
+		for name := range m {
+			res = append(res, block+"."+name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// fieldByPath 按点分隔的路径查找字段, 忽略大小写
+func fieldByPath(rv reflect.Value, fieldPath string) (reflect.Value, bool) {
+	for _, name := range strings.Split(fieldPath, ".") {
+		if rv.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		rv = rv.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if !rv.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+	return rv, true
+}
+
+// Required 字段不能为零值
+func Required() Check {
+	return func(field string, v reflect.Value) error {
+		if v.IsZero() {
+			return fmt.Errorf("%s: required", field)
+		}
+		return nil
+	}
+}
+
+// IntRange 整数字段取值在[min, max]内
+func IntRange(min, max int64) Check {
+	return func(field string, v reflect.Value) error {
+		if !v.CanInt() {
+			return fmt.Errorf("%s: not an integer", field)
+		}
+		if n := v.Int(); n < min || n > max {
+			return fmt.Errorf("%s: %d out of range [%d, %d]", field, n, min, max)
+		}
+		return nil
+	}
+}
+
+// PortRange 端口取值在[1, 65535]内
+func PortRange() Check {
+	return IntRange(1, 65535)
+}
+
+// LenIn 字符串长度必须是给定值之一
+func LenIn(lens ...int) Check {
+	return func(field string, v reflect.Value) error {
+		for _, n := range lens {
+			if v.Len() == n {
+				return nil
+			}
+		}
+		strs := make([]string, 0, len(lens))
+		for _, n := range lens {
+			strs = append(strs, strconv.Itoa(n))
+		}
+		return fmt.Errorf("%s: length %d, want %s", field, v.Len(), strings.Join(strs, "/"))
+	}
+}
+
+// OneOf 字符串字段必须是给定值之一
+func OneOf(vals ...string) Check {
+	return func(field string, v reflect.Value) error {
+		for _, s := range vals {
+			if v.String() == s {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %q, want one of %q", field, v.String(), vals)
+	}
+}
+
+// failFast 加载失败时是否直接退出
+func failFast() bool {
+	ok, _ := strconv.ParseBool(os.Getenv(FailFastEnv))
+	return ok
+}
+
+// joinLoadErr 合并加载错误与校验错误
+func joinLoadErr(loadErr, validErr error) error {
+	if loadErr == nil {
+		return validErr
+	}
+	if validErr == nil {
+		return loadErr
+	}
+	return errors.Join(loadErr, validErr)
+}
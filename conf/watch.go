@@ -0,0 +1,144 @@
+package conf
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wyy8261/gmf/logger"
+)
+
+// ChangeFunc 配置变更回调, old为变更前的配置, new为生效后的配置
+type ChangeFunc func(old, new *Config)
+
+var (
+	gWatchMutex sync.Mutex
+	gWatchStop  chan struct{}
+	gSubMutex   sync.RWMutex
+	gSubs       []ChangeFunc
+	gReloadMu   sync.Mutex
+)
+
+// OnChange 注册配置变更回调, 每次热加载成功后按注册顺序调用
+func OnChange(fn ChangeFunc) {
+	if fn == nil {
+		return
+	}
+	gSubMutex.Lock()
+	gSubs = append(gSubs, fn)
+	gSubMutex.Unlock()
+}
+
+// Reload 重新解码配置文件, 校验通过后原子替换 Default() 返回的配置并通知订阅者。
+// 解码或校验失败时保留原配置并返回错误。
+func Reload() error {
+	current()
+	gReloadMu.Lock()
+	st, err := loadState(gPath)
+	if err == nil {
+		err = st.cfg.Validate()
+	}
+	if err != nil {
+		gReloadMu.Unlock()
+		return err
+	}
+	old := Default()
+	gState.Store(st)
+	resetSections()
+	gReloadMu.Unlock()
+
+	//解锁后再通知, 回调中可以调用 Reload 或 Load
+	applyLog(st.cfg)
+	notify(old, st.cfg)
+	return nil
+}
+
+// applyLog 按 [Log] 段设置日志, 没有该段时保持 SetLogInfo 等的设置
+func applyLog(c *Config) {
+	if err := logger.Apply(c.Log); err != nil {
+		logger.LOGE("err:", err)
+	}
+}
+
+func notify(old, new *Config) {
+	gSubMutex.RLock()
+	subs := make([]ChangeFunc, len(gSubs))
+	copy(subs, gSubs)
+	gSubMutex.RUnlock()
+	for _, fn := range subs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.LOGE("conf change callback panic:", r)
+				}
+			}()
+			fn(old, new)
+		}()
+	}
+}
+
+// Watch 以interval为周期检查配置文件的修改时间, 并监听远程配置源, 变化时自动 Reload。
+// 重复调用只会启动一次监听。
+func Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = 3 * time.Second
+	}
+	gWatchMutex.Lock()
+	defer gWatchMutex.Unlock()
+	if gWatchStop != nil {
+		return
+	}
+	st := current()
+	gWatchStop = make(chan struct{})
+	go watchWork(interval, gWatchStop)
+	for _, src := range st.sources {
+		go watchSource(src, gWatchStop)
+	}
+}
+
+// StopWatch 停止配置文件监听
+func StopWatch() {
+	gWatchMutex.Lock()
+	defer gWatchMutex.Unlock()
+	if gWatchStop != nil {
+		close(gWatchStop)
+		gWatchStop = nil
+	}
+}
+
+func watchWork(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := filesStamp(current())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		st := current()
+		stamp := filesStamp(st)
+		if stamp == last {
+			continue
+		}
+		last = stamp
+		if err := Reload(); err != nil {
+			logger.LOGE("reload:", st.path, ", err:", err)
+			continue
+		}
+		logger.LOGI("reload:", gState.Load().files)
+	}
+}
+
+// filesStamp 汇总基础配置及profile文件的修改时间, 任一文件变化或新增都会改变结果
+func filesStamp(st *state) string {
+	var sb strings.Builder
+	for _, f := range profileFiles(st.fsys, st.path, st.profiles) {
+		sb.WriteString(f)
+		if fi, err := statFile(st.fsys, f); err == nil {
+			sb.WriteString(fi.ModTime().String())
+		}
+		sb.WriteByte(';')
+	}
+	return sb.String()
+}
@@ -0,0 +1,36 @@
+package conf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReloadFromCallback(t *testing.T) {
+	src := NewMemorySource("reentrant")
+	src.Set("Redis.Pwd", "v1")
+	if err := loadWith(t, src); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	nested := false
+	OnChange(func(old, new *Config) {
+		if new.Redis.Pwd != "v2" || nested {
+			return
+		}
+		//回调中再次加载不能死锁
+		nested = true
+		done <- Reload()
+	})
+	src.Set("Redis.Pwd", "v2")
+	go Reload()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Reload from OnChange callback deadlocked")
+	}
+}
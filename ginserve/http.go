@@ -2,6 +2,7 @@ package ginserve
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -15,12 +16,16 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	MAX_PRINT_BODY_LEN = 1024
 	MY_CONTEXT_NAME    = "__myData__"
+	HEADER_REQUEST_ID  = "X-Request-Id"
+	HEADER_TRACE_ID    = "X-Trace-Id"
+	DEFAULT_AES_KEY_ID = "0"
 )
 
 var (
@@ -33,8 +38,15 @@ var (
 	gHttpRequestCache           = NewHttpRequestCache()
 	gUserVerifyURI              = make(map[string]int, 0)
 	gAuthentication   AuthFunc  = defaultAuthentication
+	gKeyRing          atomic.Pointer[keyRingCache]
+	gCustomRing       atomic.Pointer[util.KeyRing]
 )
 
+type keyRingCache struct {
+	sig  string
+	ring *util.KeyRing
+}
+
 type AuthFunc func(useridx *int64, auth string) bool
 
 type tgMemoryCache struct {
@@ -68,6 +80,11 @@ func InitContext() gin.HandlerFunc {
 		c.Writer = mc.blw
 		c.Set(MY_CONTEXT_NAME, mc)
 
+		//请求ID及链路ID, 用 mc.Ctx() 记录的日志可以按请求关联
+		ctx := logger.NewContext(c.Request.Context(), c.GetHeader(HEADER_REQUEST_ID), traceId(c))
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(HEADER_REQUEST_ID, logger.RequestId(ctx))
+
 		//context.Header("Access-Control-Allow-Origin", "*")
 		//context.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token")
 		//context.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
@@ -102,6 +119,14 @@ func InitContext() gin.HandlerFunc {
 	}
 }
 
+// traceId 取W3C traceparent(00-<traceid>-<spanid>-<flags>)中的traceid, 没有时取 X-Trace-Id
+func traceId(c *gin.Context) string {
+	if tp := strings.Split(c.GetHeader("traceparent"), "-"); len(tp) == 4 && len(tp[1]) == 32 {
+		return tp[1]
+	}
+	return c.GetHeader(HEADER_TRACE_ID)
+}
+
 func CommonLogInterceptor() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var (
@@ -121,14 +146,14 @@ func CommonLogInterceptor() gin.HandlerFunc {
 		obj, ok := c.Get(MY_CONTEXT_NAME)
 		if ok {
 			mc := obj.(*MyContext)
-			bodyStr = string(mc.Ext.ReqBody)
+			bodyStr = logger.Redact(string(mc.Ext.ReqBody))
 			json.Unmarshal(mc.blw.bodyBuf.Bytes(), &res)
 			_, ok = c.Get("IsCache")
 			useridx = mc.UserIdx
 			if useridx == 0 {
 				useridx = util.Atoll(c.GetHeader("useridx"))
 			}
-			logger.LOGD("code:", mc.blw.Status(), " time:", end.Sub(start).String(), ",IsCache:", ok, ",useridx:", useridx, ",languageType:", mc.LanguageType, ", ", c.Request.Method, " URL:", c.Request.URL.Path, ",body:", bodyStr, ",[code=", res.StatusCode, ",msg=", res.Message, "]")
+			logger.LOGDC(c.Request.Context(), "code:", mc.blw.Status(), " time:", end.Sub(start).String(), ",IsCache:", ok, ",useridx:", useridx, ",languageType:", mc.LanguageType, ", ", c.Request.Method, " URL:", c.Request.URL.Path, ",body:", bodyStr, ",[code=", res.StatusCode, ",msg=", res.Message, "]")
 		}
 	}
 }
@@ -149,6 +174,7 @@ func UserVerifyInterceptor() gin.HandlerFunc {
 					c.Abort()
 					return
 				}
+				logger.SetContextUser(c.Request.Context(), mc.UserIdx)
 			}
 		}
 		c.Next()
@@ -231,6 +257,11 @@ func ClearExpireMemoryCache() {
 	}
 }
 
+// Ctx 请求的context, 带有请求ID、链路ID及用户, 如 logger.LOGIC(c.Ctx(), "xxx")
+func (c *MyContext) Ctx() context.Context {
+	return c.Request.Context()
+}
+
 func (c *MyContext) SetHttpCache(duration time.Duration) {
 	c.cacheTime = duration
 }
@@ -283,8 +314,48 @@ func (c *MyContext) SetMemoryCache(key string, val interface{}, timeout time.Dur
 	return true
 }
 
+// SetKeyRing 设置解密请求体的密钥, 为nil时使用配置中的 AesKey / AesKeys
+func SetKeyRing(r *util.KeyRing) {
+	gCustomRing.Store(r)
+}
+
+// keyRing 请求体解密密钥, 配置热加载修改密钥后重新创建
+func keyRing() *util.KeyRing {
+	if r := gCustomRing.Load(); r != nil {
+		return r
+	}
+	cfg := conf.Default()
+	if cfg.AesKey == "" {
+		return nil
+	}
+	sig := fmt.Sprint(cfg.AesKeyId, cfg.AesKey, cfg.AesKeys)
+	if kc := gKeyRing.Load(); kc != nil && kc.sig == sig {
+		return kc.ring
+	}
+	id := cfg.AesKeyId
+	if id == "" {
+		id = DEFAULT_AES_KEY_ID
+	}
+	ring := util.NewKeyRing()
+	if err := ring.Add(id, []byte(cfg.AesKey)); err != nil {
+		logger.LOGE("err:", err)
+		return nil
+	}
+	for kid, key := range cfg.AesKeys {
+		if kid == id {
+			continue
+		}
+		if err := ring.Add(kid, []byte(key)); err != nil {
+			logger.LOGE("key:", kid, ", err:", err)
+		}
+	}
+	gKeyRing.Store(&keyRingCache{sig: sig, ring: ring})
+	return ring
+}
+
 func (c *MyContext) bodyDecode() {
-	if conf.Default().AesKey == "" {
+	ring := keyRing()
+	if ring == nil {
 		return
 	}
 
@@ -297,17 +368,9 @@ func (c *MyContext) bodyDecode() {
 	if body == nil {
 		data, err := io.ReadAll(c.Request.Body)
 		if err == nil {
-			body, err = util.AesDecrypt(data, []byte(conf.Default().AesKey))
-			if err == nil {
-				buff := new(bytes.Buffer)
-				for _, by := range body {
-					if by < 0x20 {
-						break
-					}
-					buff.WriteByte(by)
-				}
-				body = buff.Bytes()
-			} else {
+			//信封格式按密钥ID解密, 旧客户端的 AES/ECB 数据用 AesKey 解密
+			body, err = ring.Decrypt(data)
+			if err != nil {
 				logger.LOGE("err:", err)
 				body = data
 			}
@@ -496,7 +559,7 @@ func UserVerify(useridx int64, token string) bool {
 }
 
 func defaultAuthentication(useridx *int64, auth string) bool {
-	logger.LOGD("auth:", auth)
+	logger.LOGD("auth:", logger.Mask(auth))
 	strs := strings.Split(auth, ".")
 	if len(strs) == 2 {
 		*useridx = util.Atoll(strs[0])
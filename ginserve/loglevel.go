@@ -0,0 +1,58 @@
+package ginserve
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wyy8261/gmf/logger"
+)
+
+// RegisterLogLevel 注册运行时调整日志级别的管理接口, 需要先使用 InitContext 中间件。
+//
+//	GET  relativePath                        查看全局级别及按包的覆盖
+//	POST relativePath?level=debug            修改全局级别
+//	POST relativePath?pkg=rmq&level=debug    修改某个包或文件(如 rabbitmq.go)的级别
+//	POST relativePath?pkg=rmq&level=reset    取消该包的覆盖
+//
+// 接口可以改变线上日志量, 建议 bVerify 为 true 或挂在受保护的路由组下
+func RegisterLogLevel(router gin.IRoutes, relativePath string, bVerify bool) {
+	RegisterGet(router, relativePath, getLogLevel, bVerify)
+	RegisterPost(router, relativePath, setLogLevel, bVerify)
+}
+
+func getLogLevel(c *MyContext, res *gin.H) {
+	(*res)["code"] = 0
+	(*res)["data"] = gin.H{
+		"level":    logger.LevelString(logger.GetLevel()),
+		"packages": logger.PackageLevels(),
+	}
+}
+
+func setLogLevel(c *MyContext, res *gin.H) {
+	pkg := c.Query("pkg")
+	if pkg == "" {
+		pkg = c.PostForm("pkg")
+	}
+	str := c.Query("level")
+	if str == "" {
+		str = c.PostForm("level")
+	}
+
+	if str == "reset" && pkg != "" {
+		logger.ClearPackageLevel(pkg)
+		logger.LOGI("log level reset, pkg:", pkg)
+		getLogLevel(c, res)
+		return
+	}
+	level, err := logger.ParseLevel(str)
+	if err != nil {
+		(*res)["code"] = HTTP_PARAM_ERROR
+		(*res)["msg"] = err.Error()
+		return
+	}
+	if pkg == "" {
+		logger.SetLevel(level)
+	} else {
+		logger.SetPackageLevel(pkg, level)
+	}
+	logger.LOGI("log level changed, pkg:", pkg, ", level:", str)
+	getLogLevel(c, res)
+}
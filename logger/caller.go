@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// callerInfo 由PC解析出的调用位置, 按PC缓存, 每个调用位置只解析一次
+type callerInfo struct {
+	file     string
+	funcname string
+	line     int
+	pkg      string
+}
+
+var (
+	sCallers  sync.Map //pc -> *callerInfo
+	sNoCaller atomic.Bool
+)
+
+// SetCaller 是否记录调用位置(文件、行号及函数), 默认记录。
+// 关闭后省去采集调用栈的开销, 但按包的级别覆盖和限流不再生效
+func SetCaller(on bool) {
+	sNoCaller.Store(!on)
+}
+
+func lookupCaller(pc uintptr) *callerInfo {
+	if v, ok := sCallers.Load(pc); ok {
+		return v.(*callerInfo)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	ci := &callerInfo{
+		file:     filepath.Base(frame.File),
+		funcname: strings.TrimPrefix(filepath.Ext(frame.Function), "."),
+		line:     frame.Line,
+		pkg:      funcPackage(frame.Function),
+	}
+	v, _ := sCallers.LoadOrStore(pc, ci)
+	return v.(*callerInfo)
+}
+
+// resolve 按需把PC解析为文件名、函数名及行号, 输出到标准输出或 Sink 前调用
+func (e *Entry) resolve() {
+	if e.pc == 0 || e.FileName != "" {
+		return
+	}
+	ci := lookupCaller(e.pc)
+	e.FileName, e.FuncName, e.Line, e.pkg = ci.file, ci.funcname, ci.line, ci.pkg
+}
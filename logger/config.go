@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config 日志配置, 对应配置文件中的 [Log] 段:
+//
+//	[Log]
+//	Level = "info"
+//	Output = ["stdout", "file"]
+//	File = "log/app.log"
+//	Format = "json"
+//	Rotate = "daily"
+//	MaxSize = 100       # MB
+//	MaxBackups = 7
+//	MaxAge = "168h"
+//	Compress = true
+//	RedactKeys = ["idcard"]
+//	[Log.Packages]
+//	rmq = "debug"
+type Config struct {
+	Level      string            //debug / info / warning / error / none, 默认debug
+	Output     []string          //stdout / file, 默认stdout
+	File       string            //日志文件路径, Output 含 file 时必填
+	Format     string            //text / json, 默认text
+	Rotate     string            //none / daily / hourly, 默认none(只按大小轮转)
+	MaxSize    int64             //单个文件最大MB, 默认100
+	MaxBackups int               //最多保留的轮转文件数, 默认7
+	MaxAge     time.Duration     //轮转文件最长保留时间, 0为不限
+	MaxTotal   int64             //轮转文件总大小上限MB, 0为不限
+	Compress   bool              //gzip压缩轮转后的文件
+	Packages   map[string]string //按包或文件设置级别, 如 rmq = "debug"
+	RedactKeys []string          //除默认外需要脱敏的字段名, 见 SetRedactKeys
+	RedactAll  bool              //对每条日志内容脱敏, 见 SetRedactAll
+}
+
+var (
+	sConfMutex sync.Mutex
+	sApplied   *Config //上次应用的配置
+)
+
+// IsZero 配置文件中没有 [Log] 段
+func (c *Config) IsZero() bool {
+	return reflect.DeepEqual(*c, Config{})
+}
+
+// Validate 检查级别、输出及格式等取值
+func (c *Config) Validate() error {
+	if c.Level != "" {
+		if _, err := ParseLevel(c.Level); err != nil {
+			return err
+		}
+	}
+	for pkg, lv := range c.Packages {
+		if _, err := ParseLevel(lv); err != nil {
+			return fmt.Errorf("logger: package %s: %w", pkg, err)
+		}
+	}
+	if _, err := c.outputType(); err != nil {
+		return err
+	}
+	switch strings.ToLower(c.Format) {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("logger: unknown format %q", c.Format)
+	}
+	switch strings.ToLower(c.Rotate) {
+	case "", "none", "daily", "hourly":
+	default:
+		return fmt.Errorf("logger: unknown rotate %q", c.Rotate)
+	}
+	return nil
+}
+
+func (c *Config) outputType() (int, error) {
+	ntype := LOG_TYPE_NONE
+	if len(c.Output) == 0 {
+		ntype = LOG_TYPE_STDOUT
+	}
+	for _, o := range c.Output {
+		switch strings.ToLower(strings.TrimSpace(o)) {
+		case "stdout":
+			ntype |= LOG_TYPE_STDOUT
+		case "file":
+			ntype |= LOG_TYPE_FILE
+		case "none":
+		default:
+			return 0, fmt.Errorf("logger: unknown output %q", o)
+		}
+	}
+	if (ntype&LOG_TYPE_FILE) > 0 && c.File == "" {
+		return 0, fmt.Errorf("logger: output file needs Log.File")
+	}
+	return ntype, nil
+}
+
+func (c *Config) rotateConf() RotateConf {
+	rc := RotateConf{
+		MaxSize:    c.MaxSize * 1024 * 1024,
+		MaxBackups: c.MaxBackups,
+		Compress:   c.Compress,
+		MaxAge:     c.MaxAge,
+		MaxTotal:   c.MaxTotal * 1024 * 1024,
+	}
+	if rc.MaxSize <= 0 {
+		rc.MaxSize = LOG_FILE_MAXSIZE
+	}
+	if rc.MaxBackups <= 0 {
+		rc.MaxBackups = LOG_SAVE_MAXSIZE
+	}
+	switch strings.ToLower(c.Rotate) {
+	case "daily":
+		rc.Interval = ROTATE_DAILY
+	case "hourly":
+		rc.Interval = ROTATE_HOURLY
+	}
+	return rc
+}
+
+// Apply 按配置设置日志, 配置热加载后再次调用时只应用有变化的部分,
+// 所以通过 SetPackageLevel 等接口在运行时做的调整在 [Log] 未修改时会保留。
+// 没有 [Log] 段时不做任何修改
+func Apply(c Config) error {
+	if c.IsZero() {
+		return nil
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	sConfMutex.Lock()
+	defer sConfMutex.Unlock()
+	old := sApplied
+	if old == nil {
+		old = &Config{}
+	}
+
+	level := LOG_LEVEL_DEBUG
+	if c.Level != "" {
+		level, _ = ParseLevel(c.Level)
+	}
+	ntype, _ := c.outputType()
+	if !strings.EqualFold(c.Format, old.Format) || sApplied == nil {
+		format := LOG_FORMAT_TEXT
+		if strings.EqualFold(c.Format, "json") {
+			format = LOG_FORMAT_JSON
+		}
+		SetLogFormat(format)
+	}
+	if rc := c.rotateConf(); sApplied == nil || rc != old.rotateConf() {
+		SetRotate(rc)
+	}
+	oldType, _ := old.outputType()
+	if sApplied == nil || ntype != oldType || c.File != old.File {
+		SetLogInfo(level, ntype, c.File)
+	} else if c.Level != old.Level {
+		SetLevel(level)
+	}
+	if sApplied == nil || !reflect.DeepEqual(c.Packages, old.Packages) {
+		ClearPackageLevel("")
+		for pkg, lv := range c.Packages {
+			n, _ := ParseLevel(lv)
+			SetPackageLevel(pkg, n)
+		}
+	}
+	if sApplied == nil || !reflect.DeepEqual(c.RedactKeys, old.RedactKeys) {
+		SetRedactKeys(c.RedactKeys...)
+	}
+	if sApplied == nil || c.RedactAll != old.RedactAll {
+		SetRedactAll(c.RedactAll)
+	}
+	sApplied = &c
+	return nil
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestApplyWhileLogging(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.log")
+	t.Cleanup(func() {
+		sConfMutex.Lock()
+		sApplied = nil
+		sConfMutex.Unlock()
+		SetLogFormat(LOG_FORMAT_TEXT)
+		SetQueue(LOG_QUEUE_MAXSIZE, LOG_QUEUE_BLOCK)
+		SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "")
+	})
+	SetQueue(256, LOG_QUEUE_BLOCK)
+	if err := Apply(Config{Output: []string{"file"}, File: file}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		stop atomic.Bool
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; !stop.Load(); j++ {
+				LOGI("reload", i, j)
+				LOGIW("reload", "i", i, "j", j)
+			}
+		}()
+	}
+	//模拟配置热加载: 切换格式、级别及文件
+	for i := 0; i < 20; i++ {
+		c := Config{Output: []string{"file"}, File: file, Level: "debug"}
+		if i%2 == 1 {
+			c.Format = "json"
+			c.Level = "info"
+			c.File = file + ".1"
+		}
+		if err := Apply(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+	Flush()
+}
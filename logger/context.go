@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+type ctxKey struct{}
+
+// ctxFields 请求级的日志字段, 用户在鉴权后才能确定, 所以 useridx 可修改
+type ctxFields struct {
+	reqId   string
+	traceId string
+	useridx atomic.Int64
+}
+
+// NewContext 返回带请求ID及链路ID的context, reqId 为空时自动生成。
+// 之后用 LOGDC 等函数记录的日志都会带上 reqid / traceid / useridx 字段
+func NewContext(parent context.Context, reqId, traceId string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if reqId == "" {
+		reqId = NewRequestId()
+	}
+	return context.WithValue(parent, ctxKey{}, &ctxFields{reqId: reqId, traceId: traceId})
+}
+
+// NewRequestId 生成16位十六进制的请求ID
+func NewRequestId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func fromContext(ctx context.Context) *ctxFields {
+	if ctx == nil {
+		return nil
+	}
+	cf, _ := ctx.Value(ctxKey{}).(*ctxFields)
+	return cf
+}
+
+// SetContextUser 设置context中的用户, 没有通过 NewContext 创建的context忽略
+func SetContextUser(ctx context.Context, useridx int64) {
+	if cf := fromContext(ctx); cf != nil {
+		cf.useridx.Store(useridx)
+	}
+}
+
+// RequestId context中的请求ID
+func RequestId(ctx context.Context) string {
+	if cf := fromContext(ctx); cf != nil {
+		return cf.reqId
+	}
+	return ""
+}
+
+// ContextFields context中的日志字段, 空值不输出
+func ContextFields(ctx context.Context) []Field {
+	cf := fromContext(ctx)
+	if cf == nil {
+		return nil
+	}
+	fields := make([]Field, 0, 3)
+	fields = append(fields, Field{Key: "reqid", Value: cf.reqId})
+	if cf.traceId != "" {
+		fields = append(fields, Field{Key: "traceid", Value: cf.traceId})
+	}
+	if useridx := cf.useridx.Load(); useridx != 0 {
+		fields = append(fields, Field{Key: "useridx", Value: useridx})
+	}
+	return fields
+}
+
+// 记录调试日志, 带上ctx中的请求字段
+func LOGDC(ctx context.Context, args ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_DEBUG) {
+		l.output(LOG_LEVEL_DEBUG, sprint(args), ContextFields(ctx))
+	}
+}
+
+// 记录信息日志, 带上ctx中的请求字段
+func LOGIC(ctx context.Context, args ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_INFO) {
+		l.output(LOG_LEVEL_INFO, sprint(args), ContextFields(ctx))
+	}
+}
+
+// 记录警告日志, 带上ctx中的请求字段
+func LOGWC(ctx context.Context, args ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_WARNING) {
+		l.output(LOG_LEVEL_WARNING, sprint(args), ContextFields(ctx))
+	}
+}
+
+// 记录错误日志, 带上ctx中的请求字段
+func LOGEC(ctx context.Context, args ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_ERROR) {
+		l.output(LOG_LEVEL_ERROR, sprint(args), ContextFields(ctx))
+	}
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var sRepanic atomic.Bool
+
+// SetRepanic 为true时 Recover 记录日志后重新panic, 进程仍按原样崩溃; 默认只记录不退出
+func SetRepanic(on bool) {
+	sRepanic.Store(on)
+}
+
+// Recover 捕获panic, 把堆栈同步写入日志并刷新队列, 须直接 defer 调用:
+//
+//	defer logger.Recover()
+func Recover() {
+	if r := recover(); r != nil {
+		crash(r)
+		if sRepanic.Load() {
+			panic(r)
+		}
+	}
+}
+
+// RecoverErr 同 Recover, 并把panic转为错误写入errp, 用于有错误返回值的回调:
+//
+//	func handle() (err error) {
+//		defer logger.RecoverErr(&err)
+//		...
+//	}
+func RecoverErr(errp *error) {
+	if r := recover(); r != nil {
+		crash(r)
+		if sRepanic.Load() {
+			panic(r)
+		}
+		if errp != nil {
+			*errp = fmt.Errorf("panic: %v", r)
+		}
+	}
+}
+
+// Go 启动协程, 协程中的panic由 Recover 处理。
+// 只用于一次性的任务; 常驻的循环panic后协程即退出, 进程却继续运行, 应让其崩溃或自行重启
+func Go(fn func()) {
+	go func() {
+		defer Recover()
+		fn()
+	}()
+}
+
+// Wrap 返回带 Recover 的函数, 用于交给定时器等在别处启动的回调
+func Wrap(fn func()) func() {
+	return func() {
+		defer Recover()
+		fn()
+	}
+}
+
+// SetCrashFile 未被捕获的panic及fatal error由运行时追加写入filename, 进程崩溃时也不会丢失堆栈
+func SetCrashFile(filename string) error {
+	if err := createDir(filepath.Dir(filename)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return debug.SetCrashOutput(f, debug.CrashOptions{})
+}
+
+// crash 以ERROR级别记录panic及堆栈, 调用位置为panic发生处; 不经过队列同步写入, 队列满时也不会丢失
+func crash(r interface{}) {
+	unit := Entry{
+		Level:   LOG_LEVEL_ERROR,
+		AddTime: time.Now(),
+		LogStr:  fmt.Sprintf("panic: %v\n%s", r, debug.Stack()),
+	}
+	if frame, ok := panicFrame(); ok {
+		unit.FileName = filepath.Base(frame.File)
+		unit.FuncName = strings.TrimPrefix(filepath.Ext(frame.Function), ".")
+		unit.Line = frame.Line
+	}
+	std().writeSync(&unit)
+}
+
+// panicFrame 调用栈中 runtime.gopanic 之后的第一帧, 即panic发生的位置
+func panicFrame() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	inPanic := false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			inPanic = true
+		} else if inPanic && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
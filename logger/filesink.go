@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+)
+
+// FileSink 写文件, 按 RotateConf 轮转, 与 LOG_TYPE_FILE 的输出相同
+type FileSink struct {
+	filename string
+	mutex    sync.Mutex
+	bf       bytes.Buffer
+	rotate   RotateConf
+	period   string //当前文件所属的轮转周期
+	dirOk    bool
+}
+
+// NewFileSink 创建文件输出, 目录不存在时在首次写入时创建
+func NewFileSink(filename string, rotate RotateConf) *FileSink {
+	return &FileSink{filename: filename, rotate: rotate}
+}
+
+// SetRotate 修改轮转策略
+func (s *FileSink) SetRotate(conf RotateConf) {
+	s.mutex.Lock()
+	s.rotate = conf
+	s.mutex.Unlock()
+}
+
+func (s *FileSink) Write(e *Entry, line []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bf.Write(line)
+	if s.bf.Len() > LOG_BUFFER_MAXSIZE {
+		return s.writeFile()
+	}
+	return nil
+}
+
+func (s *FileSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeFile()
+}
+
+func (s *FileSink) Close() error {
+	return s.Flush()
+}
+
+func (s *FileSink) writeFile() error {
+	if s.bf.Len() == 0 {
+		return nil
+	}
+	if !s.dirOk {
+		if err := createDir(path.Dir(s.filename)); err != nil {
+			s.bf.Reset()
+			return fmt.Errorf("path: %s, create logger directory failed: %w", s.filename, err)
+		}
+		s.dirOk = true
+	}
+	s.rotateIfNeeded(s.filename)
+	f, err := os.OpenFile(s.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		s.bf.Reset()
+		return err
+	}
+	s.bf.WriteTo(f)
+	return f.Close()
+}
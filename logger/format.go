@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Field 结构化日志的键值对
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// JSON 格式中的固定字段, 同名的自定义字段会加上 "field." 前缀
+var reservedKeys = map[string]bool{"time": true, "level": true, "file": true, "line": true, "func": true, "msg": true}
+
+// SetLogFormat 设置输出格式 LOG_FORMAT_TEXT / LOG_FORMAT_JSON, 标准输出和文件同时生效
+func SetLogFormat(format int) {
+	sMutex.Lock()
+	defer sMutex.Unlock()
+	l := std()
+	l.format_.Store(int32(format))
+	l.updateSink(FILE_SINK_NAME, func(s *sinkEntry) {
+		s.format = format
+	})
+}
+
+// 记录调试日志, kv 为交替的键值, 如 LOGDW("login", "useridx", 1001, "ip", ip)
+func LOGDW(msg string, kv ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_DEBUG) {
+		l.output(LOG_LEVEL_DEBUG, msg, kvFields(kv))
+	}
+}
+
+// 记录信息日志, kv 为交替的键值
+func LOGIW(msg string, kv ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_INFO) {
+		l.output(LOG_LEVEL_INFO, msg, kvFields(kv))
+	}
+}
+
+// 记录警告日志, kv 为交替的键值
+func LOGWW(msg string, kv ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_WARNING) {
+		l.output(LOG_LEVEL_WARNING, msg, kvFields(kv))
+	}
+}
+
+// 记录错误日志, kv 为交替的键值
+func LOGEW(msg string, kv ...interface{}) {
+	if l := std(); l.enabled(LOG_LEVEL_ERROR) {
+		l.output(LOG_LEVEL_ERROR, msg, kvFields(kv))
+	}
+}
+
+// kvFields 把交替的键值转为字段, 也可以直接传 Field; 缺少值的键记为 "!MISSING"
+func kvFields(kv []interface{}) []Field {
+	if len(kv) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i++ {
+		if f, ok := kv[i].(Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		if i+1 >= len(kv) {
+			fields = append(fields, Field{Key: key, Value: "!MISSING"})
+			break
+		}
+		fields = append(fields, Field{Key: key, Value: kv[i+1]})
+		i++
+	}
+	return fields
+}
+
+var sBufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return sBufPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer 过大的缓冲不放回, 避免长期占用内存
+func putBuffer(bf *bytes.Buffer) {
+	if bf.Cap() > 64*1024 {
+		return
+	}
+	bf.Reset()
+	sBufPool.Put(bf)
+}
+
+func formatTime(t time.Time) string {
+	return string(appendTime(nil, t))
+}
+
+// appendTime 格式为 2006-01-02 15:04:05 000
+func appendTime(b []byte, t time.Time) []byte {
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	b = strconv.AppendInt(b, int64(year), 10)
+	b = append(b, '-')
+	b = append2(b, int(month))
+	b = append(b, '-')
+	b = append2(b, day)
+	b = append(b, ' ')
+	b = append2(b, hour)
+	b = append(b, ':')
+	b = append2(b, min)
+	b = append(b, ':')
+	b = append2(b, sec)
+	b = append(b, ' ')
+	ms := t.Nanosecond() / 1e6
+	return append(b, byte('0'+ms/100), byte('0'+ms/10%10), byte('0'+ms%10))
+}
+
+func append2(b []byte, n int) []byte {
+	return append(b, byte('0'+n/10), byte('0'+n%10))
+}
+
+// formatLine 按格式写入一行, 文本格式带耗时
+func formatLine(bf *bytes.Buffer, unit *Entry, format int) {
+	if format == LOG_FORMAT_JSON {
+		formatJSON(bf, unit)
+		return
+	}
+	formatText(bf, unit, true)
+}
+
+// formatText [时间] [耗时] [级别] 文件(行号), 函数: 内容 key=value...
+func formatText(bf *bytes.Buffer, unit *Entry, elapsed bool) {
+	b := bf.AvailableBuffer()
+	b = append(b, '[')
+	b = appendTime(b, unit.AddTime)
+	b = append(b, "] "...)
+	if elapsed {
+		b = append(b, '[')
+		b = appendElapsed(b, unit.AddTime)
+		b = append(b, "] "...)
+	}
+	b = append(b, '[')
+	b = append(b, sLogLevelstr[unit.Level]...)
+	b = append(b, "] "...)
+	b = append(b, unit.FileName...)
+	b = append(b, '(')
+	b = strconv.AppendInt(b, int64(unit.Line), 10)
+	b = append(b, "), "...)
+	b = append(b, unit.FuncName...)
+	b = append(b, ": "...)
+	bf.Write(b)
+	bf.WriteString(unit.LogStr)
+	for _, f := range unit.Fields {
+		bf.WriteByte(' ')
+		bf.WriteString(f.Key)
+		bf.WriteByte('=')
+		bf.WriteString(textValue(f.Value))
+	}
+	bf.WriteByte('\n')
+}
+
+func textValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case error:
+		s = t.Error()
+	case fmt.Stringer:
+		s = t.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// formatJSON 每行一个JSON对象, 自定义字段与固定字段平级
+func formatJSON(bf *bytes.Buffer, unit *Entry) {
+	bf.WriteString(`{"time":`)
+	writeJSON(bf, unit.AddTime.Format("2006-01-02 15:04:05.000"))
+	bf.WriteString(`,"level":`)
+	writeJSON(bf, sLogLevelstr[unit.Level])
+	bf.WriteString(`,"file":`)
+	writeJSON(bf, unit.FileName)
+	bf.WriteString(`,"line":`)
+	bf.WriteString(strconv.Itoa(unit.Line))
+	bf.WriteString(`,"func":`)
+	writeJSON(bf, unit.FuncName)
+	bf.WriteString(`,"msg":`)
+	writeJSON(bf, unit.LogStr)
+	for _, f := range unit.Fields {
+		key := f.Key
+		if reservedKeys[key] {
+			key = "field." + key
+		}
+		bf.WriteByte(',')
+		writeJSON(bf, key)
+		bf.WriteByte(':')
+		writeJSON(bf, jsonValue(f.Value))
+	}
+	bf.WriteString("}\n")
+}
+
+// jsonValue error 等类型序列化后为空对象, 转成字符串
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case time.Duration:
+		return t.String()
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
+
+func writeJSON(bf *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	bf.Write(data)
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// levelTable 全局级别及按包/文件的覆盖, 修改时整体替换, 读取无需加锁
+type levelTable struct {
+	level     int            //全局级别
+	overrides map[string]int //键为包名(rmq)、包路径(github.com/wyy8261/gmf/rmq)或文件名(rabbitmq.go)
+	min       int            //所有级别中最低的, 用于快速过滤
+}
+
+func newLevelTable(level int, overrides map[string]int) *levelTable {
+	t := &levelTable{level: level, overrides: overrides, min: level}
+	for _, lv := range overrides {
+		if lv < t.min {
+			t.min = lv
+		}
+	}
+	return t
+}
+
+// ParseLevel 解析级别名称: debug / info / warning(warn) / error / none, 不区分大小写
+func ParseLevel(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LOG_LEVEL_DEBUG, nil
+	case "info":
+		return LOG_LEVEL_INFO, nil
+	case "warning", "warn":
+		return LOG_LEVEL_WARNING, nil
+	case "error":
+		return LOG_LEVEL_ERROR, nil
+	case "none":
+		return LOG_LEVEL_NONE, nil
+	}
+	return 0, fmt.Errorf("logger: unknown level %q", s)
+}
+
+// LevelString 级别名称, 与 ParseLevel 对应
+func LevelString(level int) string {
+	if level >= LOG_LEVEL_DEBUG && level < LOG_LEVEL_NONE {
+		return strings.ToLower(sLogLevelstr[level])
+	}
+	return "none"
+}
+
+// SetLevel 运行时修改全局级别, 按包的覆盖保持不变
+func SetLevel(level int) {
+	sMutex.Lock()
+	defer sMutex.Unlock()
+	l := std()
+	l.levels_.Store(newLevelTable(level, l.levels_.Load().overrides))
+}
+
+// GetLevel 当前全局级别
+func GetLevel() int {
+	return std().levels_.Load().level
+}
+
+// SetPackageLevel 为某个包或文件单独设置级别, 如 SetPackageLevel("rmq", LOG_LEVEL_DEBUG)。
+// pkg 可以是包名、完整包路径或文件名(以 .go 结尾), 同时匹配时文件名优先, 其次完整路径
+func SetPackageLevel(pkg string, level int) {
+	sMutex.Lock()
+	defer sMutex.Unlock()
+	l := std()
+	old := l.levels_.Load()
+	overrides := make(map[string]int, len(old.overrides)+1)
+	for k, v := range old.overrides {
+		overrides[k] = v
+	}
+	overrides[pkg] = level
+	l.levels_.Store(newLevelTable(old.level, overrides))
+}
+
+// ClearPackageLevel 取消包或文件的级别覆盖, pkg 为空时全部取消
+func ClearPackageLevel(pkg string) {
+	sMutex.Lock()
+	defer sMutex.Unlock()
+	l := std()
+	old := l.levels_.Load()
+	overrides := make(map[string]int, len(old.overrides))
+	if pkg != "" {
+		for k, v := range old.overrides {
+			if k != pkg {
+				overrides[k] = v
+			}
+		}
+	}
+	l.levels_.Store(newLevelTable(old.level, overrides))
+}
+
+// PackageLevels 当前所有的级别覆盖, 按键排序为 "pkg=level"
+func PackageLevels() []string {
+	overrides := std().levels_.Load().overrides
+	res := make([]string, 0, len(overrides))
+	for k, v := range overrides {
+		res = append(res, k+"="+LevelString(v))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// enabled 快速判断, 任一包可能输出该级别时为真, 精确判断见 levelOf
+func (l *Logger) enabled(level int) bool {
+	return l.levels_.Load().min <= level
+}
+
+// levelOf 调用位置生效的级别, pkg 为完整包路径
+func (l *Logger) levelOf(filename, pkg string) int {
+	t := l.levels_.Load()
+	if len(t.overrides) == 0 {
+		return t.level
+	}
+	if lv, ok := t.overrides[filename]; ok {
+		return lv
+	}
+	if lv, ok := t.overrides[pkg]; ok {
+		return lv
+	}
+	if lv, ok := t.overrides[path.Base(pkg)]; ok {
+		return lv
+	}
+	return t.level
+}
+
+// funcPackage 从完整函数名中取出包路径, 如 github.com/wyy8261/gmf/rmq.(*RabbitMQ).Send
+func funcPackage(funcname string) string {
+	slash := strings.LastIndexByte(funcname, '/')
+	if dot := strings.IndexByte(funcname[slash+1:], '.'); dot >= 0 {
+		return funcname[:slash+1+dot]
+	}
+	return funcname
+}
@@ -1,35 +1,51 @@
 package logger
 
 import (
-	"bytes"
-	"container/list"
 	"fmt"
 	"os"
-	"path"
-	"path/filepath"
 	"runtime"
-	"strings"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Logger struct {
-	level_    int
+	levels_   atomic.Pointer[levelTable]
 	type_     int
+	format_   atomic.Int32
 	filename_ string
-	logList_  *list.List
-	mutex_    sync.Mutex
-	stop_     bool
-	rouse_    chan int
+	sinks_    atomic.Pointer[[]*sinkEntry]
+	queue_    *ring         //待输出到 Sink 的日志, 有界
+	rouse_    chan struct{} //唤醒输出协程
+	sleeping_ atomic.Bool   //输出协程在等待唤醒
+	smutex_   sync.Mutex
+	space_    *sync.Cond         //队列有空位或输出协程退出, 唤醒 LOG_QUEUE_BLOCK 下等待的写日志协程
+	waiters_  atomic.Int32       //在 space_ 上等待的协程数
+	qsize_    int                //队列长度
+	policy_   atomic.Int32       //队列满时的处理 LOG_QUEUE_BLOCK / LOG_QUEUE_DROP
+	dropped_  atomic.Uint64      //因队列满丢弃的条数
+	flush_    chan chan struct{} //Flush 请求, 写完后关闭应答通道
+	stop_     chan struct{}
+	done_     chan struct{} //输出协程退出后关闭
+	started_  sync.Once
+	closed_   sync.Once
+	closing_  atomic.Bool //已开始关闭, 之后的日志不再入队
+	dmutex_   *sync.Mutex //保证 Sink 串行调用: 替换前后的输出协程及同步输出(关闭后、panic时)共用
+	rotate_   RotateConf
 }
 
-type stLogUnit struct {
+// Entry 一条日志记录
+type Entry struct {
 	FileName string
 	FuncName string
 	Line     int
 	Level    int
 	LogStr   string
+	Fields   []Field
 	AddTime  time.Time
+	pc       uintptr //调用位置, 输出前由 resolve 解析为文件名等
+	pkg      string
 }
 
 const (
@@ -46,18 +62,39 @@ const (
 	LOG_TYPE_FILE   = 2 //写文件
 )
 
+const (
+	LOG_FORMAT_TEXT = 0 //文本行
+	LOG_FORMAT_JSON = 1 //每行一个JSON对象
+)
+
+const (
+	LOG_QUEUE_BLOCK = 0 //队列满时等待写入
+	LOG_QUEUE_DROP  = 1 //队列满时丢弃, 计入 Dropped
+)
+
 const (
 	LOG_BUFFER_MAXSIZE = 4096
+	LOG_QUEUE_MAXSIZE  = 10000
 	LOG_FILE_MAXSIZE   = 100 * 1024 * 1024 //100M
 	LOG_SAVE_MAXSIZE   = 7
 )
 
 var (
-	slogger        = &Logger{type_: LOG_TYPE_STDOUT}
+	sLogger        atomic.Pointer[Logger]
+	sMutex         sync.Mutex //保护日志设置的修改及 Logger 的替换
 	sLogLevelstr   = [4]string{"DEBUG", "INFO", "WARNING", "ERROR"}
 	sLogPrintColor = [4]int{38, 36, 33, 31}
 )
 
+func init() {
+	sLogger.Store(newLogger(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "", nil))
+}
+
+// std 当前使用的 Logger, SetLogInfo 时整体替换
+func std() *Logger {
+	return sLogger.Load()
+}
+
 func createDir(dir string) error {
 	_, err := os.Stat(dir)
 	if err == nil {
@@ -69,228 +106,383 @@ func createDir(dir string) error {
 	return nil
 }
 
+// SetLogInfo 设置日志级别、输出类型及文件名, 切换后旧的 Logger 写完已入队的日志再关闭
 func SetLogInfo(level, ntype int, filename string) {
-	slogger = &Logger{
-		level_:    level,
+	sMutex.Lock()
+	old := std()
+	sLogger.Store(newLogger(level, ntype, filename, old))
+	sMutex.Unlock()
+
+	old.close()
+	for _, s := range *old.sinks_.Load() {
+		if s.name == FILE_SINK_NAME {
+			s.sink.Close()
+		}
+	}
+}
+
+// newLogger 创建日志, 沿用old的格式、队列、轮转、级别覆盖及附加的 Sink
+func newLogger(level, ntype int, filename string, old *Logger) *Logger {
+	l := &Logger{
 		type_:     ntype,
-		stop_:     false,
-		rouse_:    make(chan int),
-		logList_:  list.New(),
 		filename_: filename,
+		qsize_:    LOG_QUEUE_MAXSIZE,
+		rotate_:   DefaultRotateConf(),
 	}
-	if (slogger.type_ & LOG_TYPE_FILE) > 0 {
-		go loggerWork()
-	} else {
-		slogger.stop_ = true
+	var overrides map[string]int
+	sinks := make([]*sinkEntry, 0)
+	if old != nil {
+		l.format_.Store(old.format_.Load())
+		l.dmutex_ = old.dmutex_
+		l.qsize_ = old.qsize_
+		l.policy_.Store(old.policy_.Load())
+		l.dropped_.Store(old.dropped_.Load())
+		l.rotate_ = old.rotate_
+		overrides = old.levels_.Load().overrides
+		for _, s := range *old.sinks_.Load() {
+			if s.name != FILE_SINK_NAME {
+				sinks = append(sinks, s)
+			}
+		}
+	}
+	if l.dmutex_ == nil {
+		l.dmutex_ = &sync.Mutex{}
+	}
+	l.levels_.Store(newLevelTable(level, overrides))
+	l.queue_ = newRing(l.qsize_)
+	l.rouse_ = make(chan struct{}, 1)
+	l.space_ = sync.NewCond(&l.smutex_)
+	l.flush_ = make(chan chan struct{})
+	l.stop_ = make(chan struct{})
+	l.done_ = make(chan struct{})
+	if (l.type_ & LOG_TYPE_FILE) > 0 {
+		sinks = append(sinks, &sinkEntry{
+			name:   FILE_SINK_NAME,
+			sink:   NewFileSink(filename, l.rotate_),
+			level:  LOG_LEVEL_DEBUG,
+			format: int(l.format_.Load()),
+		})
+	}
+	l.sinks_.Store(&sinks)
+	if len(sinks) > 0 {
+		l.start()
+	}
+	return l
+}
+
+// SetQueue 设置写文件队列的长度及队列满时的处理方式, 立即生效;
+// 长度变化时同 SetLogInfo, 先写完已入队的日志再换用新的队列
+func SetQueue(size, policy int) {
+	if size <= 0 {
+		size = LOG_QUEUE_MAXSIZE
+	}
+	sMutex.Lock()
+	l := std()
+	l.policy_.Store(int32(policy))
+	resize := l.qsize_ != size
+	l.qsize_ = size
+	sMutex.Unlock()
+	if resize {
+		SetLogInfo(l.levels_.Load().level, l.type_, l.filename_)
 	}
 }
 
+// Dropped 因队列满被丢弃的日志条数
+func Dropped() uint64 {
+	return std().dropped_.Load()
+}
+
+// Flush 把队列中的日志全部交给 Sink 并刷新后返回
+func Flush() {
+	std().flush()
+}
+
+// Close 写完队列中的日志, 停止输出协程并关闭所有 Sink, 之后的日志直接同步输出。程序退出前调用
+func Close() {
+	l := std()
+	l.close()
+	for _, s := range *l.sinks_.Load() {
+		if err := s.sink.Close(); err != nil {
+			fmt.Println("sink:", s.name, ", close err:", err)
+		}
+	}
+}
+
+// SyncToFile 同 Flush, 保留旧接口
+func SyncToFile() {
+	Flush()
+}
+
 func elapsedTime(t time.Time) string {
+	return string(appendElapsed(nil, t))
+}
+
+func appendElapsed(b []byte, t time.Time) []byte {
 	count := time.Now().Sub(t).Microseconds()
 	if count > 1000 {
-		return fmt.Sprintf("%dms", count/1000)
+		return append(strconv.AppendInt(b, count/1000, 10), "ms"...)
 	}
-	return fmt.Sprintf("%dus", count)
+	return append(strconv.AppendInt(b, count, 10), "us"...)
 }
 
-func loggerWork() {
-	err := createDir(path.Dir(slogger.filename_))
-	if err != nil {
-		fmt.Println("path:", slogger.filename_, ", Create logger directory failed...")
-		return
-	}
+func (l *Logger) start() {
+	l.started_.Do(func() {
+		go l.work()
+	})
+}
+
+func (l *Logger) work() {
+	defer l.signalSpace()
+	defer close(l.done_)
 	ticker := time.NewTicker(3 * time.Second)
-	var bf bytes.Buffer
+	defer ticker.Stop()
 	for {
-		var ok bool = false
+		l.drain()
+		//先标记再检查, 与 enqueue 中先入队再检查标记配合, 不会漏掉唤醒
+		l.sleeping_.Store(true)
+		if !l.queue_.empty() {
+			l.sleeping_.Store(false)
+			//写日志的协程持续入队时也要响应 Flush 及关闭
+			select {
+			case reply := <-l.flush_:
+				l.flushReply(reply)
+			case <-l.stop_:
+				l.stop()
+				return
+			default:
+			}
+			continue
+		}
 		select {
-		case <-slogger.rouse_:
-			ok = true
+		case <-l.rouse_:
 		case <-ticker.C:
+			l.flushSinks()
+		case reply := <-l.flush_:
+			l.flushReply(reply)
+		case <-l.stop_:
+			l.stop()
+			return
 		}
-		if slogger.stop_ {
-			break
-		}
-		if ok {
-			for {
-				unit, ok := slogger.dequeue()
-				if ok {
-					timeStr := fmt.Sprintf("%d-%02d-%02d %02d:%02d:%02d %03d", unit.AddTime.Year(), unit.AddTime.Month(), unit.AddTime.Day(), unit.AddTime.Hour(), unit.AddTime.Minute(), unit.AddTime.Second(), unit.AddTime.UnixNano()/1e6%1000)
-					bf.WriteString(fmt.Sprintf("[%s] [%s] [%s] %s(%d), %s: %s\n", timeStr, elapsedTime(unit.AddTime), sLogLevelstr[unit.Level], unit.FileName, unit.Line, unit.FuncName, unit.LogStr))
-					if bf.Len() > LOG_BUFFER_MAXSIZE {
-						slogger.writeFile(slogger.filename_, &bf)
-					}
-				} else {
-					break
-				}
-			}
-		} else {
-			ok, size := slogger.getFileByteSize(slogger.filename_)
-			if ok && size > LOG_FILE_MAXSIZE {
-				slogger.reuseFile(slogger.filename_, LOG_SAVE_MAXSIZE)
-			}
-			slogger.writeFile(slogger.filename_, &bf)
+		l.sleeping_.Store(false)
+	}
+}
+
+func (l *Logger) flushReply(reply chan struct{}) {
+	l.drain()
+	l.flushSinks()
+	close(reply)
+}
+
+// stop 写完队列中剩余的日志; closing_ 已设置, 不会再有新的日志入队
+func (l *Logger) stop() {
+	for !l.queue_.empty() {
+		l.drain()
+	}
+	l.flushSinks()
+}
+
+// drain 输出开始时队列中已有的日志, 之后入队的留给下一次, 避免生产者持续入队时无法返回
+func (l *Logger) drain() {
+	l.dmutex_.Lock()
+	defer l.dmutex_.Unlock()
+	end := l.queue_.tail.Load()
+	var e Entry
+	for l.queue_.head.Load() < end && l.queue_.pop(&e) {
+		l.signalSpace()
+		l.dispatch(&e)
+	}
+}
+
+// signalSpace 唤醒等待队列空位的协程
+func (l *Logger) signalSpace() {
+	if l.waiters_.Load() > 0 {
+		l.smutex_.Lock()
+		l.space_.Broadcast()
+		l.smutex_.Unlock()
+	}
+}
+
+func (l *Logger) wake() {
+	if l.sleeping_.Load() {
+		select {
+		case l.rouse_ <- struct{}{}:
+		default:
 		}
 	}
 }
 
-// 获取文件名称及行号
-func getFilenameAndLine() (string, string, int) {
-	pc, filename, line, ok := runtime.Caller(2)
-	if ok {
-		funcname := runtime.FuncForPC(pc).Name()
-		funcname = filepath.Ext(funcname)
-		funcname = strings.TrimPrefix(funcname, ".")
-		filename = filepath.Base(filename)
-		return filename, funcname, line
+func (l *Logger) flush() {
+	if len(*l.sinks_.Load()) == 0 {
+		return
+	}
+	l.start()
+	reply := make(chan struct{})
+	select {
+	case l.flush_ <- reply:
+		<-reply
+	case <-l.done_:
 	}
-	return "", "", 0
+}
+
+// close 停止输出协程, 不关闭 Sink
+func (l *Logger) close() {
+	l.start()
+	l.closed_.Do(func() {
+		//先标记再唤醒等待的协程, 它们改为同步输出
+		l.closing_.Store(true)
+		l.signalSpace()
+		close(l.stop_)
+	})
+	<-l.done_
 }
 
 // 记录调试日志（级别最低）
 func LOGD(args ...interface{}) {
-	if slogger.level_ <= LOG_LEVEL_DEBUG {
-		unit := &stLogUnit{}
-		unit.FileName, unit.FuncName, unit.Line = getFilenameAndLine()
-		unit.AddTime = time.Now()
-		unit.Level = LOG_LEVEL_DEBUG
-		unit.LogStr = fmt.Sprint(args...)
-		slogger.formatWriteLogMsg(unit)
+	if l := std(); l.enabled(LOG_LEVEL_DEBUG) {
+		l.output(LOG_LEVEL_DEBUG, sprint(args), nil)
 	}
 }
 
 // 记录信息日志（级别一般）
 func LOGI(args ...interface{}) {
-	if slogger.level_ <= LOG_LEVEL_INFO {
-		unit := &stLogUnit{}
-		unit.FileName, unit.FuncName, unit.Line = getFilenameAndLine()
-		unit.AddTime = time.Now()
-		unit.Level = LOG_LEVEL_INFO
-		unit.LogStr = fmt.Sprint(args...)
-		slogger.formatWriteLogMsg(unit)
+	if l := std(); l.enabled(LOG_LEVEL_INFO) {
+		l.output(LOG_LEVEL_INFO, sprint(args), nil)
 	}
 }
 
 // 记录警告日志（级别较高）
 func LOGW(args ...interface{}) {
-	if slogger.level_ <= LOG_LEVEL_WARNING {
-		unit := &stLogUnit{}
-		unit.FileName, unit.FuncName, unit.Line = getFilenameAndLine()
-		unit.AddTime = time.Now()
-		unit.Level = LOG_LEVEL_WARNING
-		unit.LogStr = fmt.Sprint(args...)
-		slogger.formatWriteLogMsg(unit)
+	if l := std(); l.enabled(LOG_LEVEL_WARNING) {
+		l.output(LOG_LEVEL_WARNING, sprint(args), nil)
 	}
 }
 
 // 记录错误及异常日志（级别最高）
 func LOGE(args ...interface{}) {
-	if slogger.level_ <= LOG_LEVEL_ERROR {
-		unit := &stLogUnit{}
-		unit.FileName, unit.FuncName, unit.Line = getFilenameAndLine()
-		unit.AddTime = time.Now()
-		unit.Level = LOG_LEVEL_ERROR
-		unit.LogStr = fmt.Sprint(args...)
-		slogger.formatWriteLogMsg(unit)
+	if l := std(); l.enabled(LOG_LEVEL_ERROR) {
+		l.output(LOG_LEVEL_ERROR, sprint(args), nil)
 	}
 }
 
-func (l *Logger) formatWriteLogMsg(unit *stLogUnit) {
-	if (l.type_ & LOG_TYPE_STDOUT) > 0 {
-		timeStr := fmt.Sprintf("%d-%02d-%02d %02d:%02d:%02d %03d", unit.AddTime.Year(), unit.AddTime.Month(), unit.AddTime.Day(), unit.AddTime.Hour(), unit.AddTime.Minute(), unit.AddTime.Second(), unit.AddTime.UnixNano()/1e6%1000)
-		fmt.Printf("%c[%dm[%s] [%s] %s(%d), %s: %s%c[0m\n", 0x1B, sLogPrintColor[unit.Level], timeStr, sLogLevelstr[unit.Level], unit.FileName, unit.Line, unit.FuncName, unit.LogStr, 0x1B)
-	}
-	if (l.type_ & LOG_TYPE_FILE) > 0 {
-		l.enqueue(unit)
+// sprint 同 fmt.Sprint, 只有一个字符串参数时不做格式化
+func sprint(args []interface{}) string {
+	if len(args) == 1 {
+		if s, ok := args[0].(string); ok {
+			return s
+		}
 	}
+	return fmt.Sprint(args...)
 }
 
-func (l *Logger) dequeue() (*stLogUnit, bool) {
-	var unit stLogUnit
-
-	slogger.mutex_.Lock()
-	defer slogger.mutex_.Unlock()
-	if l.logList_.Len() > 0 {
-		front := slogger.logList_.Front()
-		unit = front.Value.(stLogUnit)
-		slogger.logList_.Remove(front)
-	} else {
-		return nil, false
+// output 记录调用位置后输出, 只能由 LOGD 这一层的导出函数直接调用
+func (l *Logger) output(level int, msg string, fields []Field) {
+	unit := Entry{Level: level, AddTime: time.Now()}
+	if !sNoCaller.Load() {
+		var pcs [1]uintptr
+		//跳过 runtime.Callers、output 及 LOGD
+		if runtime.Callers(3, pcs[:]) > 0 {
+			unit.pc = pcs[0]
+		}
+	}
+	if !l.allow(&unit) {
+		return
 	}
-	return &unit, true
+	unit.LogStr = msg
+	unit.Fields = fields
+	l.formatWriteLogMsg(&unit)
 }
 
-func (l *Logger) enqueue(unit *stLogUnit) {
-	l.mutex_.Lock()
-	l.logList_.PushBack(*unit)
-	l.mutex_.Unlock()
-	select {
-	case l.rouse_ <- 1:
-	default:
+// allow 按包的级别覆盖及限流判断是否输出, 没有覆盖和限流时不解析调用位置
+func (l *Logger) allow(unit *Entry) bool {
+	if len(l.levels_.Load().overrides) > 0 {
+		unit.resolve()
+		if unit.Level < l.levelOf(unit.FileName, unit.pkg) {
+			return false
+		}
 	}
+	return sampled(unit)
 }
 
-func (l *Logger) getFileByteSize(filename string) (bool, int64) {
-	bExist, fhandler := l.isFile(filename)
-	if !bExist {
-		return false, 0
+func (l *Logger) formatWriteLogMsg(unit *Entry) {
+	l.writeStdout(unit)
+	if len(*l.sinks_.Load()) > 0 {
+		l.enqueue(unit)
 	}
-	return true, fhandler.Size()
 }
 
-func (l *Logger) isFile(filename string) (bool, os.FileInfo) {
-	fhandler, err := os.Stat(filename)
-	if !(err == nil || os.IsExist(err)) {
-		return false, nil
-	} else if fhandler.IsDir() {
-		return false, nil
+// writeSync 不经过队列同步写入所有输出并刷新, 之前入队的日志先写完; 用于进程可能随即退出的场合
+func (l *Logger) writeSync(unit *Entry) {
+	l.writeStdout(unit)
+	if len(*l.sinks_.Load()) > 0 {
+		l.flush()
+		l.dispatchSync(unit)
 	}
-	return true, fhandler
 }
 
-func (l *Logger) reuseFile(filename string, maxsize int) {
-	suffix := path.Ext(filename)
-	prefix := strings.TrimSuffix(filename, suffix)
-	oldest := time.Now().Unix()
-	var newpath string
-	for i := 1; i <= maxsize; i++ {
-		fname := fmt.Sprintf("%s.%d%s", prefix, i, suffix)
-		bExist, fhandler := l.isFile(fname)
-		if !bExist {
-			os.Rename(filename, fname)
-			return
-		}
-		if oldest > fhandler.ModTime().Unix() {
-			oldest = fhandler.ModTime().Unix()
-			newpath = fname
+// writeStdout 脱敏后输出到控制台
+func (l *Logger) writeStdout(unit *Entry) {
+	redactEntry(unit)
+	if (l.type_ & LOG_TYPE_STDOUT) > 0 {
+		unit.resolve()
+		bf := getBuffer()
+		if l.format_.Load() == LOG_FORMAT_JSON {
+			formatJSON(bf, unit)
+		} else {
+			bf.WriteString("\x1b[")
+			bf.Write(strconv.AppendInt(bf.AvailableBuffer(), int64(sLogPrintColor[unit.Level]), 10))
+			bf.WriteByte('m')
+			formatText(bf, unit, false)
+			bf.Truncate(bf.Len() - 1)
+			bf.WriteString("\x1b[0m\n")
 		}
+		os.Stdout.Write(bf.Bytes())
+		putBuffer(bf)
 	}
-	os.Rename(filename, newpath)
 }
 
-func (l *Logger) writeFile(filename string, bf *bytes.Buffer) error {
-	if bf.Len() == 0 {
-		return nil
+// enqueue 复制到队列, 队列满时按 policy_ 挂起等待或丢弃
+func (l *Logger) enqueue(unit *Entry) {
+	if l.closed() {
+		l.dispatchClosed(unit)
+		return
 	}
-	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		return err
+	if l.queue_.push(unit) {
+		l.wake()
+		return
+	}
+	if l.policy_.Load() == LOG_QUEUE_DROP {
+		l.dropped_.Add(1)
+		return
 	}
-	bf.WriteTo(f)
-	return f.Close()
-}
 
-func SyncToFile() {
-	var bf bytes.Buffer
+	//先登记再重试入队, 与 drain 中先出队再检查 waiters_ 配合, 不会漏掉唤醒
+	l.smutex_.Lock()
+	l.waiters_.Add(1)
 	for {
-		unit, ok := slogger.dequeue()
-		if ok {
-			bf.WriteString(fmt.Sprintf("[%s] [%s] %s(%d), %s: %s\n", unit.AddTime, sLogLevelstr[unit.Level], unit.FileName, unit.Line, unit.FuncName, unit.LogStr))
-		} else {
-			if bf.Len() > 0 {
-				slogger.writeFile(slogger.filename_, &bf)
-			}
+		//先检查关闭, 关闭后不再占用队列
+		if l.closed() {
+			l.waiters_.Add(-1)
+			l.smutex_.Unlock()
+			l.dispatchClosed(unit)
+			return
+		}
+		if l.queue_.push(unit) {
 			break
 		}
+		l.wake()
+		l.space_.Wait()
 	}
+	l.waiters_.Add(-1)
+	l.smutex_.Unlock()
+	l.wake()
+}
+
+func (l *Logger) closed() bool {
+	return l.closing_.Load()
+}
+
+// dispatchClosed 已开始关闭, 直接同步输出
+func (l *Logger) dispatchClosed(unit *Entry) {
+	e := *unit
+	l.dispatchSync(&e)
 }
@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// discardSink 丢弃输出, 只测量日志本身的开销
+type discardSink struct{}
+
+func (discardSink) Write(e *Entry, line []byte) error { return nil }
+func (discardSink) Flush() error                      { return nil }
+func (discardSink) Close() error                      { return nil }
+
+func setupBench(b *testing.B) {
+	SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_NONE, "")
+	AddSink("bench", discardSink{}, LOG_LEVEL_DEBUG, LOG_FORMAT_TEXT)
+	b.Cleanup(func() {
+		RemoveSink("bench")
+		SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "")
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+}
+
+func BenchmarkLOGI(b *testing.B) {
+	setupBench(b)
+	for i := 0; i < b.N; i++ {
+		LOGI("user login, useridx:", i, ", ip:", "127.0.0.1")
+	}
+	Flush()
+}
+
+func BenchmarkLOGIParallel(b *testing.B) {
+	setupBench(b)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			LOGI("user login, useridx:", i, ", ip:", "127.0.0.1")
+			i++
+		}
+	})
+	Flush()
+}
+
+func BenchmarkLOGIW(b *testing.B) {
+	setupBench(b)
+	for i := 0; i < b.N; i++ {
+		LOGIW("user login", "useridx", i, "ip", "127.0.0.1")
+	}
+	Flush()
+}
+
+func BenchmarkLOGDFiltered(b *testing.B) {
+	setupBench(b)
+	SetLevel(LOG_LEVEL_INFO)
+	for i := 0; i < b.N; i++ {
+		LOGD("user login, useridx:", i, ", ip:", "127.0.0.1")
+	}
+}
+
+// legacyLogger 改造前的写文件路径: runtime.Caller + FuncForPC + fmt.Sprint,
+// 加锁的 container/list 队列, 输出协程用 fmt.Sprintf 格式化, 作为对比基准
+type legacyLogger struct {
+	mutex sync.Mutex
+	list  *list.List
+	rouse chan int
+}
+
+type legacyUnit struct {
+	FileName string
+	FuncName string
+	Line     int
+	Level    int
+	LogStr   string
+	AddTime  time.Time
+}
+
+func (l *legacyLogger) output(level int, args ...interface{}) {
+	unit := &legacyUnit{}
+	pc, filename, line, ok := runtime.Caller(1)
+	if ok {
+		unit.FuncName = strings.TrimPrefix(filepath.Ext(runtime.FuncForPC(pc).Name()), ".")
+		unit.FileName = filepath.Base(filename)
+		unit.Line = line
+	}
+	unit.AddTime = time.Now()
+	unit.Level = level
+	unit.LogStr = fmt.Sprint(args...)
+	l.mutex.Lock()
+	l.list.PushBack(*unit)
+	l.mutex.Unlock()
+	select {
+	case l.rouse <- 1:
+	default:
+	}
+}
+
+func (l *legacyLogger) dequeue() (*legacyUnit, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	front := l.list.Front()
+	if front == nil {
+		return nil, false
+	}
+	unit := front.Value.(legacyUnit)
+	l.list.Remove(front)
+	return &unit, true
+}
+
+func (l *legacyLogger) format(unit *legacyUnit) string {
+	t := unit.AddTime
+	str := fmt.Sprintf("[%d-%02d-%02d %02d:%02d:%02d %03d] ", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.UnixNano()/1e6%1000)
+	str += fmt.Sprintf("[%s] ", elapsedTime(t))
+	str += fmt.Sprintf("[%s] %s(%d), %s: %s\n", sLogLevelstr[unit.Level], unit.FileName, unit.Line, unit.FuncName, unit.LogStr)
+	return str
+}
+
+// run 模拟 loggerWork, 返回等待队列取空的函数
+func (l *legacyLogger) run(stop chan struct{}) (wait func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var n int
+		for {
+			select {
+			case <-l.rouse:
+			case <-stop:
+				for unit, ok := l.dequeue(); ok; unit, ok = l.dequeue() {
+					n += len(l.format(unit))
+				}
+				return
+			}
+			for unit, ok := l.dequeue(); ok; unit, ok = l.dequeue() {
+				n += len(l.format(unit))
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func BenchmarkLegacy(b *testing.B) {
+	l := &legacyLogger{list: list.New(), rouse: make(chan int)}
+	wait := l.run(make(chan struct{}))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.output(LOG_LEVEL_INFO, "user login, useridx:", i, ", ip:", "127.0.0.1")
+	}
+	wait()
+}
+
+func BenchmarkLegacyParallel(b *testing.B) {
+	l := &legacyLogger{list: list.New(), rouse: make(chan int)}
+	wait := l.run(make(chan struct{}))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			l.output(LOG_LEVEL_INFO, "user login, useridx:", i, ", ip:", "127.0.0.1")
+			i++
+		}
+	})
+	wait()
+}
+
+func BenchmarkLOGINoCaller(b *testing.B) {
+	setupBench(b)
+	SetCaller(false)
+	defer SetCaller(true)
+	for i := 0; i < b.N; i++ {
+		LOGI("user login, useridx:", i, ", ip:", "127.0.0.1")
+	}
+	Flush()
+}
+
+func BenchmarkLOGIFile(b *testing.B) {
+	SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_FILE, filepath.Join(b.TempDir(), "bench.log"))
+	b.Cleanup(func() {
+		SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "")
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		LOGI("user login, useridx:", i, ", ip:", "127.0.0.1")
+	}
+	Flush()
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countSink 记录写入的条数, delay 模拟较慢的输出
+type countSink struct {
+	n     atomic.Int64
+	delay time.Duration
+}
+
+func (s *countSink) Write(e *Entry, line []byte) error {
+	s.n.Add(1)
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s *countSink) Flush() error { return nil }
+func (s *countSink) Close() error { return nil }
+
+// withDeadline fn在d内没有返回时失败
+func withDeadline(t *testing.T, what string, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatal(what, "did not return within", d)
+	}
+}
+
+func TestBlockedProducersFlushAndClose(t *testing.T) {
+	SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_NONE, "")
+	SetLogFormat(LOG_FORMAT_JSON)
+	SetQueue(8, LOG_QUEUE_BLOCK)
+	sink := &countSink{delay: 20 * time.Microsecond}
+	AddSink("count", sink, LOG_LEVEL_DEBUG, LOG_FORMAT_JSON)
+	t.Cleanup(func() {
+		RemoveSink("count")
+		SetLogFormat(LOG_FORMAT_TEXT)
+		SetQueue(LOG_QUEUE_MAXSIZE, LOG_QUEUE_BLOCK)
+		SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "")
+	})
+
+	var (
+		stop atomic.Bool
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; !stop.Load(); j++ {
+				LOGIW("producer", "i", i, "j", j)
+			}
+		}()
+	}
+	//队列已满, 生产者挂起等待
+	deadline := time.Now().Add(3 * time.Second)
+	for sink.n.Load() < 100 || std().waiters_.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("producers never blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		withDeadline(t, "Flush", 5*time.Second, Flush)
+	}
+	old := std()
+	withDeadline(t, "close", 5*time.Second, old.close)
+	stop.Store(true)
+	withDeadline(t, "producers", 5*time.Second, wg.Wait)
+	if old.waiters_.Load() != 0 {
+		t.Fatalf("%d producers still parked", old.waiters_.Load())
+	}
+}
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LOG_NET_BUFFER_MAXSIZE = 1024 * 1024 //断线时最多缓存1M
+	LOG_NET_RETRY_MAX      = 30 * time.Second
+)
+
+// NetSink 通过TCP或UDP逐行发送日志, 如发往 logstash / fluentd。
+// 连接及发送在独立的协程中进行, 不阻塞写日志; 断线后缓存日志并按指数退避重连, 缓存超过 MaxBuffer 时丢弃最早的日志
+type NetSink struct {
+	Network   string        //tcp / udp
+	Addr      string        //如 127.0.0.1:5000
+	Timeout   time.Duration //连接及写超时, 默认3秒
+	MaxBuffer int           //断线时最多缓存的字节数, 默认 LOG_NET_BUFFER_MAXSIZE
+
+	mutex   sync.Mutex
+	pending [][]byte
+	size    int
+	dropped atomic.Uint64
+	started sync.Once
+	closed  sync.Once
+	rouse   chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+
+	//以下只由发送协程使用
+	dial     func(network, addr string, timeout time.Duration) (net.Conn, error)
+	conn     net.Conn
+	retry    time.Duration
+	nextDial time.Time
+	failed   bool
+}
+
+// NewNetSink 创建网络输出, 首次发送时才连接
+func NewNetSink(network, addr string) *NetSink {
+	return &NetSink{Network: network, Addr: addr}
+}
+
+// Dropped 因缓存已满被丢弃的条数
+func (s *NetSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *NetSink) Write(e *Entry, line []byte) error {
+	s.start()
+	s.mutex.Lock()
+	s.pending = append(s.pending, append([]byte(nil), line...))
+	s.size += len(line)
+	s.trim()
+	full := s.size > LOG_BUFFER_MAXSIZE
+	s.mutex.Unlock()
+	if full {
+		s.wake()
+	}
+	return nil
+}
+
+// Flush 通知发送协程发送缓存的日志, 不等待发送完成
+func (s *NetSink) Flush() error {
+	s.start()
+	s.wake()
+	return nil
+}
+
+// Close 停止发送协程, 停止前再尝试发送一次缓存的日志
+func (s *NetSink) Close() error {
+	s.start()
+	s.closed.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
+
+func (s *NetSink) start() {
+	s.started.Do(func() {
+		if s.dial == nil {
+			s.dial = net.DialTimeout
+		}
+		s.rouse = make(chan struct{}, 1)
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.work()
+	})
+}
+
+func (s *NetSink) wake() {
+	select {
+	case s.rouse <- struct{}{}:
+	default:
+	}
+}
+
+// trim 缓存超过上限时丢弃最早的日志, 调用时已持有s.mutex
+func (s *NetSink) trim() {
+	max := s.MaxBuffer
+	if max <= 0 {
+		max = LOG_NET_BUFFER_MAXSIZE
+	}
+	for s.size > max && len(s.pending) > 1 {
+		s.size -= len(s.pending[0])
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+		s.dropped.Add(1)
+	}
+}
+
+func (s *NetSink) work() {
+	defer close(s.done)
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-s.rouse:
+		case <-retry:
+			retry = nil
+		case <-s.stop:
+			s.send(true)
+			if s.conn != nil {
+				s.conn.Close()
+				s.conn = nil
+			}
+			return
+		}
+		if s.send(false) {
+			retry = nil
+		} else if retry == nil {
+			retry = time.After(time.Until(s.nextDial))
+		}
+	}
+}
+
+func (s *NetSink) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return 3 * time.Second
+}
+
+// send 发送缓存的日志, 连接及发送时不持有锁; 失败时未发送的部分放回缓存等待重连。
+// force 为true时忽略重连间隔, 用于关闭前的最后一次发送
+func (s *NetSink) send(force bool) bool {
+	if s.conn == nil && !force && time.Now().Before(s.nextDial) {
+		return false
+	}
+	s.mutex.Lock()
+	batch := s.pending
+	s.pending, s.size = nil, 0
+	s.mutex.Unlock()
+	if len(batch) == 0 {
+		return true
+	}
+
+	if s.conn == nil {
+		conn, err := s.dial(s.Network, s.Addr, s.timeout())
+		if err != nil {
+			s.requeue(batch)
+			s.fail(err)
+			return false
+		}
+		s.conn = conn
+	}
+	for i, line := range batch {
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+		if _, err := s.conn.Write(line); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			s.requeue(batch[i:])
+			s.fail(err)
+			return false
+		}
+	}
+	s.failed = false
+	s.retry = 0
+	return true
+}
+
+// requeue 未发送的日志放回缓存最前面
+func (s *NetSink) requeue(lines [][]byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, line := range lines {
+		s.size += len(line)
+	}
+	s.pending = append(lines, s.pending...)
+	s.trim()
+}
+
+// fail 记录下次重连的时间, 连续失败只输出一次错误
+func (s *NetSink) fail(err error) {
+	if s.retry == 0 {
+		s.retry = time.Second
+	} else if s.retry < LOG_NET_RETRY_MAX {
+		s.retry *= 2
+		if s.retry > LOG_NET_RETRY_MAX {
+			s.retry = LOG_NET_RETRY_MAX
+		}
+	}
+	s.nextDial = time.Now().Add(s.retry)
+	if !s.failed {
+		s.failed = true
+		fmt.Println("netsink:", s.Network, s.Addr, ", err:", err)
+	}
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 连接不上采集端时, Write 及 Flush 不能阻塞输出协程
+func TestNetSinkDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	server, client := net.Pipe()
+	dials := 0
+	s := NewNetSink("tcp", "collector:5000")
+	s.dial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		dials++
+		<-release //模拟连接超时
+		if dials == 1 {
+			return nil, errors.New("unreachable")
+		}
+		return client, nil
+	}
+
+	line := []byte(strings.Repeat("x", 100) + "\n")
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		s.Write(nil, line)
+		s.Flush()
+	}
+	if cost := time.Since(start); cost > 100*time.Millisecond {
+		t.Fatalf("Write blocked for %v", cost)
+	}
+
+	//第一次连接失败, 退避后重连成功, 发送断线期间缓存的日志
+	close(release)
+	r := bufio.NewReader(server)
+	deadline := time.Now().Add(5 * time.Second)
+	server.SetReadDeadline(deadline)
+	for i := 0; i < 100; i++ {
+		got, err := r.ReadString('\n')
+		if err != nil || got != string(line) {
+			t.Fatalf("line %d: %q, %v", i, got, err)
+		}
+	}
+	go func() {
+		//Close 时最后一次发送的数据
+		r.ReadString('\n')
+	}()
+	s.Close()
+}
+
+func TestNetSinkBufferLimit(t *testing.T) {
+	s := NewNetSink("tcp", "collector:5000")
+	s.MaxBuffer = 1000
+	s.dial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("unreachable")
+	}
+	line := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 50; i++ {
+		s.Write(nil, line)
+	}
+	s.Close()
+	s.mutex.Lock()
+	size, n := s.size, len(s.pending)
+	s.mutex.Unlock()
+	if size > 1000 || n != 10 || s.Dropped() != 40 {
+		t.Fatalf("size %d, pending %d, dropped %d", size, n, s.Dropped())
+	}
+}
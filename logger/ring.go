@@ -0,0 +1,67 @@
+package logger
+
+import "sync/atomic"
+
+// ring 预分配的有界无锁队列, 多个生产者单个消费者。
+// 每个槽位用序号标记状态: seq == pos 可写, seq == pos+1 可读
+type ring struct {
+	mask  uint64
+	slots []ringSlot
+	head  atomic.Uint64 //下一个读取位置, 只由消费者修改
+	tail  atomic.Uint64 //下一个写入位置
+}
+
+type ringSlot struct {
+	seq atomic.Uint64
+	e   Entry
+}
+
+// newRing 容量向上取2的幂
+func newRing(size int) *ring {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	r := &ring{mask: uint64(n - 1), slots: make([]ringSlot, n)}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// push 复制e到队列, 队列满时返回false
+func (r *ring) push(e *Entry) bool {
+	for {
+		pos := r.tail.Load()
+		slot := &r.slots[pos&r.mask]
+		seq := slot.seq.Load()
+		if seq == pos {
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				slot.e = *e
+				slot.seq.Store(pos + 1)
+				return true
+			}
+		} else if seq < pos {
+			return false
+		}
+	}
+}
+
+// pop 取出一条到e, 队列空时返回false, 只能由消费者调用
+func (r *ring) pop(e *Entry) bool {
+	pos := r.head.Load()
+	slot := &r.slots[pos&r.mask]
+	if slot.seq.Load() != pos+1 {
+		return false
+	}
+	*e = slot.e
+	slot.e = Entry{}
+	slot.seq.Store(pos + r.mask + 1)
+	r.head.Store(pos + 1)
+	return true
+}
+
+func (r *ring) empty() bool {
+	pos := r.head.Load()
+	return r.slots[pos&r.mask].seq.Load() != pos+1
+}
@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ROTATE_NONE   = 0 //只按大小轮转
+	ROTATE_DAILY  = 1 //按天轮转
+	ROTATE_HOURLY = 2 //按小时轮转
+)
+
+// RotateConf 日志文件轮转及保留策略, 各项为0表示不限制
+type RotateConf struct {
+	MaxSize    int64         //单个文件最大字节数
+	Interval   int           //ROTATE_NONE / ROTATE_DAILY / ROTATE_HOURLY
+	Compress   bool          //轮转后的文件用gzip压缩
+	MaxBackups int           //最多保留的轮转文件数
+	MaxAge     time.Duration //轮转文件最长保留时间
+	MaxTotal   int64         //轮转文件总大小上限, 超出时从最旧的开始删除
+}
+
+// DefaultRotateConf 与旧版一致: 100M轮转, 保留7个文件
+func DefaultRotateConf() RotateConf {
+	return RotateConf{MaxSize: LOG_FILE_MAXSIZE, MaxBackups: LOG_SAVE_MAXSIZE}
+}
+
+// SetRotate 设置当前日志文件的轮转策略
+func SetRotate(conf RotateConf) {
+	sMutex.Lock()
+	defer sMutex.Unlock()
+	l := std()
+	l.rotate_ = conf
+	for _, se := range *l.sinks_.Load() {
+		if se.name == FILE_SINK_NAME {
+			if fs, ok := se.sink.(*FileSink); ok {
+				fs.SetRotate(conf)
+			}
+		}
+	}
+}
+
+// periodKey 时间所在的轮转周期, 同时用作轮转文件名中的时间戳
+func periodKey(t time.Time, interval int) string {
+	switch interval {
+	case ROTATE_DAILY:
+		return t.Format("2006-01-02")
+	case ROTATE_HOURLY:
+		return t.Format("2006-01-02-15")
+	}
+	return ""
+}
+
+// rotateIfNeeded 写入前检查是否跨周期或超过大小, 需要时轮转, 调用时已持有s.mutex
+func (s *FileSink) rotateIfNeeded(filename string) {
+	conf := s.rotate
+
+	bExist, fi := isFile(filename)
+	if !bExist {
+		return
+	}
+	if s.period == "" {
+		//进程启动时以已有文件的修改时间确定所属周期
+		s.period = periodKey(fi.ModTime(), conf.Interval)
+	}
+	now := periodKey(time.Now(), conf.Interval)
+	switch {
+	case now != s.period:
+		s.rotateFile(filename, s.period, conf)
+	case conf.MaxSize > 0 && fi.Size() > conf.MaxSize:
+		stamp := s.period
+		if stamp == "" {
+			stamp = time.Now().Format("2006-01-02-150405")
+		}
+		s.rotateFile(filename, stamp, conf)
+	}
+	s.period = now
+}
+
+// rotateFile 把当前文件改名为 前缀.时间戳[.序号]后缀, 如 app.2006-01-02.1.log
+func (s *FileSink) rotateFile(filename, stamp string, conf RotateConf) {
+	suffix := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filename, suffix)
+	newpath := fmt.Sprintf("%s.%s%s", prefix, stamp, suffix)
+	for i := 1; exist(newpath) || exist(newpath+".gz"); i++ {
+		newpath = fmt.Sprintf("%s.%s.%d%s", prefix, stamp, i, suffix)
+	}
+	if err := os.Rename(filename, newpath); err != nil {
+		fmt.Println("rotate:", filename, ", err:", err)
+		return
+	}
+	//压缩和清理放到后台, 不阻塞写日志
+	go func() {
+		if conf.Compress {
+			if err := gzipFile(newpath); err != nil {
+				fmt.Println("compress:", newpath, ", err:", err)
+			}
+		}
+		cleanBackups(filename, conf)
+	}()
+}
+
+func exist(filename string) bool {
+	bExist, _ := isFile(filename)
+	return bExist
+}
+
+func isFile(filename string) (bool, os.FileInfo) {
+	fhandler, err := os.Stat(filename)
+	if !(err == nil || os.IsExist(err)) {
+		return false, nil
+	} else if fhandler.IsDir() {
+		return false, nil
+	}
+	return true, fhandler
+}
+
+func gzipFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(src+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(src + ".gz")
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// backupPattern 轮转文件名: 前缀.时间戳[.序号]后缀[.gz], 时间戳为 periodKey 或按大小轮转时的秒级时间,
+// 不匹配同目录下其他日志, 如 app.log 不匹配 app.err.log
+func backupPattern(filename string) *regexp.Regexp {
+	suffix := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filepath.Base(filename), suffix)
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `\.\d{4}-\d{2}-\d{2}(?:-\d{2}(?:\d{4})?)?(?:\.\d+)?` +
+		regexp.QuoteMeta(suffix) + `(?:\.gz)?$`)
+}
+
+// backups 返回filename的所有轮转文件, 按修改时间从新到旧排列
+func backups(filename string) []os.FileInfo {
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return nil
+	}
+	re := backupPattern(filename)
+	res := make([]os.FileInfo, 0)
+	for _, e := range entries {
+		if e.IsDir() || !re.MatchString(e.Name()) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			res = append(res, fi)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ModTime().After(res[j].ModTime())
+	})
+	return res
+}
+
+// cleanBackups 按数量、时间及总大小删除过期的轮转文件
+func cleanBackups(filename string, conf RotateConf) {
+	var (
+		dir   = filepath.Dir(filename)
+		total int64
+	)
+	for i, fi := range backups(filename) {
+		total += fi.Size()
+		expired := (conf.MaxBackups > 0 && i >= conf.MaxBackups) ||
+			(conf.MaxAge > 0 && time.Since(fi.ModTime()) > conf.MaxAge) ||
+			(conf.MaxTotal > 0 && total > conf.MaxTotal)
+		if expired {
+			os.Remove(filepath.Join(dir, fi.Name()))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestBackupsMatchOwnPattern(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"app.log",
+		"app.2024-05-01.log",
+		"app.2024-05-01.2.log",
+		"app.2024-05-01-13.log.gz",
+		"app.2024-05-01-130405.1.log",
+		//同目录下的其他日志及无关文件
+		"app.err.log",
+		"app.err.2024-05-01.log",
+		"app.2024-05-01.log.bak",
+		"app2.2024-05-01.log",
+		"app.2024-05-01.txt",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, fi := range backups(filepath.Join(dir, "app.log")) {
+		got = append(got, fi.Name())
+	}
+	sort.Strings(got)
+	want := []string{"app.2024-05-01-13.log.gz", "app.2024-05-01-130405.1.log", "app.2024-05-01.2.log", "app.2024-05-01.log"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type siteCounter struct {
+	mutex      sync.Mutex
+	start      time.Time //当前周期的开始时间
+	count      int       //当前周期内的条数
+	suppressed int       //未汇报的被抑制条数
+	level      int
+}
+
+// sampler 按调用位置限流: 每个周期内前 first 条全部输出, 之后每 thereafter 条输出一条
+type sampler struct {
+	first      int
+	thereafter int
+	interval   time.Duration
+	sites      sync.Map //调用位置的pc -> *siteCounter
+	stop       chan struct{}
+}
+
+var sSampler atomic.Pointer[sampler]
+
+// SetSampling 开启按调用位置的限流, 同一行代码在 interval 内前 first 条全部输出, 之后每 thereafter 条输出一条,
+// thereafter 为0时不再输出; 每个周期为被抑制的位置输出一行 "suppressed N messages"。first 小于等于0时关闭。
+// 错误日志不限流
+func SetSampling(first, thereafter int, interval time.Duration) {
+	var s *sampler
+	if first > 0 {
+		if interval <= 0 {
+			interval = time.Second
+		}
+		s = &sampler{first: first, thereafter: thereafter, interval: interval, stop: make(chan struct{})}
+		go s.work()
+	}
+	if old := sSampler.Swap(s); old != nil {
+		close(old.stop)
+		old.report()
+	}
+}
+
+// sampled 是否输出这一条, 未开启限流、没有调用位置或错误日志时总是输出
+func sampled(unit *Entry) bool {
+	s := sSampler.Load()
+	if s == nil || unit.pc == 0 || unit.Level >= LOG_LEVEL_ERROR {
+		return true
+	}
+	return s.allow(unit)
+}
+
+func (s *sampler) allow(unit *Entry) bool {
+	v, ok := s.sites.Load(unit.pc)
+	if !ok {
+		v, _ = s.sites.LoadOrStore(unit.pc, &siteCounter{start: unit.AddTime})
+	}
+	sc := v.(*siteCounter)
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if unit.AddTime.Sub(sc.start) >= s.interval {
+		sc.start = unit.AddTime
+		sc.count = 0
+	}
+	sc.count++
+	if sc.count <= s.first || (s.thereafter > 0 && (sc.count-s.first)%s.thereafter == 0) {
+		return true
+	}
+	sc.suppressed++
+	sc.level = unit.Level
+	return false
+}
+
+func (s *sampler) work() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.report()
+		}
+	}
+}
+
+// report 输出各位置被抑制的条数, 长时间没有日志的位置删除
+func (s *sampler) report() {
+	now := time.Now()
+	s.sites.Range(func(k, v interface{}) bool {
+		sc := v.(*siteCounter)
+		sc.mutex.Lock()
+		n := sc.suppressed
+		unit := Entry{Level: sc.level, pc: k.(uintptr)}
+		sc.suppressed = 0
+		idle := now.Sub(sc.start) > 10*s.interval
+		sc.mutex.Unlock()
+		if n > 0 {
+			unit.AddTime = now
+			unit.LogStr = fmt.Sprintf("suppressed %d messages", n)
+			unit.Fields = []Field{{Key: "suppressed", Value: n}}
+			std().formatWriteLogMsg(&unit)
+		} else if idle {
+			s.sites.Delete(k)
+		}
+		return true
+	})
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	s := &sampler{first: 3, thereafter: 4, interval: time.Second}
+	start := time.Now()
+	unit := func(pc uintptr, d time.Duration) *Entry {
+		return &Entry{Level: LOG_LEVEL_INFO, pc: pc, AddTime: start.Add(d)}
+	}
+
+	var passed []int
+	for i := 1; i <= 12; i++ {
+		if s.allow(unit(1, time.Duration(i)*time.Millisecond)) {
+			passed = append(passed, i)
+		}
+	}
+	//前3条全部输出, 之后每4条输出一条
+	if want := []int{1, 2, 3, 7, 11}; !slices.Equal(passed, want) {
+		t.Fatalf("passed %v, want %v", passed, want)
+	}
+	v, _ := s.sites.Load(uintptr(1))
+	if n := v.(*siteCounter).suppressed; n != 7 {
+		t.Fatalf("suppressed %d, want 7", n)
+	}
+
+	//不同调用位置分别计数
+	if !s.allow(unit(2, 20*time.Millisecond)) {
+		t.Fatal("other call site sampled")
+	}
+
+	//下一个周期重新计数
+	for i := 1; i <= 3; i++ {
+		if !s.allow(unit(1, time.Second+time.Duration(i)*time.Millisecond)) {
+			t.Fatalf("entry %d of next interval dropped", i)
+		}
+	}
+	if s.allow(unit(1, time.Second+4*time.Millisecond)) {
+		t.Fatal("entry 4 of next interval passed")
+	}
+}
+
+func TestSamplerReport(t *testing.T) {
+	SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_NONE, "")
+	sink := &lineSink{}
+	AddSink("lines", sink, LOG_LEVEL_DEBUG, LOG_FORMAT_TEXT)
+	t.Cleanup(func() {
+		RemoveSink("lines")
+		SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "")
+	})
+
+	s := &sampler{first: 1, interval: time.Hour}
+	for i := 0; i < 5; i++ {
+		s.allow(&Entry{Level: LOG_LEVEL_WARNING, pc: 1, AddTime: time.Now()})
+	}
+	s.report()
+	s.report()
+	Flush()
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	//汇报后清零, 第二次没有可汇报的
+	if len(sink.lines) != 1 || !strings.Contains(sink.lines[0], "suppressed 4 messages") {
+		t.Fatalf("got %q", sink.lines)
+	}
+}
+
+func TestSamplingSkipsErrors(t *testing.T) {
+	SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_NONE, "")
+	sink := &lineSink{}
+	AddSink("lines", sink, LOG_LEVEL_DEBUG, LOG_FORMAT_TEXT)
+	SetSampling(1, 0, time.Hour)
+	t.Cleanup(func() {
+		SetSampling(0, 0, 0)
+		RemoveSink("lines")
+		SetLogInfo(LOG_LEVEL_DEBUG, LOG_TYPE_STDOUT, "")
+	})
+
+	for i := 0; i < 5; i++ {
+		LOGI("info line")
+		LOGE("error line")
+	}
+	Flush()
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	infos, errs := 0, 0
+	for _, line := range sink.lines {
+		if strings.Contains(line, "info line") {
+			infos++
+		}
+		if strings.Contains(line, "error line") {
+			errs++
+		}
+	}
+	if infos != 1 || errs != 5 {
+		t.Fatalf("info %d, error %d; want 1 and 5", infos, errs)
+	}
+}
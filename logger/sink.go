@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// FILE_SINK_NAME SetLogInfo 使用 LOG_TYPE_FILE 时创建的文件输出的名称
+const FILE_SINK_NAME = "file"
+
+// Sink 日志输出。Write 由输出协程串行调用, line 为按该输出的格式化好的一行(以换行结尾),
+// e 和 line 在返回后都会被复用, 不能再引用; Flush 在定时、Flush() 及关闭时调用; Close 在移除或 Close() 时调用
+type Sink interface {
+	Write(e *Entry, line []byte) error
+	Flush() error
+	Close() error
+}
+
+type sinkEntry struct {
+	name   string
+	sink   Sink
+	level  int
+	format int
+}
+
+// AddSink 添加一个输出, 同名的输出会被替换并关闭。
+// level 为该输出的最低级别, 只能比全局级别更严格; format 为 LOG_FORMAT_TEXT / LOG_FORMAT_JSON
+func AddSink(name string, s Sink, level, format int) {
+	sMutex.Lock()
+	l := std()
+	old := *l.sinks_.Load()
+	sinks := make([]*sinkEntry, 0, len(old)+1)
+	var replaced Sink
+	for _, se := range old {
+		if se.name == name {
+			replaced = se.sink
+			continue
+		}
+		sinks = append(sinks, se)
+	}
+	sinks = append(sinks, &sinkEntry{name: name, sink: s, level: level, format: format})
+	l.sinks_.Store(&sinks)
+	sMutex.Unlock()
+
+	l.start()
+	if replaced != nil {
+		//等输出协程处理完手上的日志后再关闭
+		l.flush()
+		replaced.Close()
+	}
+}
+
+// RemoveSink 移除输出, 移除前写完队列中的日志
+func RemoveSink(name string) {
+	std().flush()
+	sMutex.Lock()
+	l := std()
+	old := *l.sinks_.Load()
+	sinks := make([]*sinkEntry, 0, len(old))
+	var removed Sink
+	for _, se := range old {
+		if se.name == name {
+			removed = se.sink
+			continue
+		}
+		sinks = append(sinks, se)
+	}
+	l.sinks_.Store(&sinks)
+	sMutex.Unlock()
+
+	if removed != nil {
+		l.flush()
+		removed.Close()
+	}
+}
+
+// Sinks 当前所有输出的名称
+func Sinks() []string {
+	res := make([]string, 0)
+	for _, se := range *std().sinks_.Load() {
+		res = append(res, se.name)
+	}
+	return res
+}
+
+// updateSink 修改名为name的输出的设置, 调用时已持有sMutex
+func (l *Logger) updateSink(name string, fn func(s *sinkEntry)) {
+	old := *l.sinks_.Load()
+	sinks := make([]*sinkEntry, 0, len(old))
+	for _, se := range old {
+		if se.name == name {
+			cp := *se
+			fn(&cp)
+			se = &cp
+		}
+		sinks = append(sinks, se)
+	}
+	l.sinks_.Store(&sinks)
+}
+
+// dispatch 按各输出的级别及格式输出一条日志, 同一格式只格式化一次
+func (l *Logger) dispatch(e *Entry) {
+	var lines [2]*bytes.Buffer
+	for _, se := range *l.sinks_.Load() {
+		if e.Level < se.level {
+			continue
+		}
+		format := LOG_FORMAT_TEXT
+		if se.format == LOG_FORMAT_JSON {
+			format = LOG_FORMAT_JSON
+		}
+		if lines[format] == nil {
+			e.resolve()
+			lines[format] = getBuffer()
+			formatLine(lines[format], e, format)
+		}
+		if err := se.sink.Write(e, lines[format].Bytes()); err != nil {
+			fmt.Println("sink:", se.name, ", write err:", err)
+		}
+	}
+	for _, bf := range lines {
+		if bf != nil {
+			putBuffer(bf)
+		}
+	}
+}
+
+// dispatchSync 不经过输出协程同步输出并刷新
+func (l *Logger) dispatchSync(e *Entry) {
+	l.dmutex_.Lock()
+	l.dispatch(e)
+	l.dmutex_.Unlock()
+	l.flushSinks()
+}
+
+func (l *Logger) flushSinks() {
+	l.dmutex_.Lock()
+	defer l.dmutex_.Unlock()
+	for _, se := range *l.sinks_.Load() {
+		if err := se.sink.Flush(); err != nil {
+			fmt.Println("sink:", se.name, ", flush err:", err)
+		}
+	}
+}
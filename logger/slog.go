@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// SlogHandler 把 log/slog 的记录写入gmf日志, 与 LOGD 等共用级别过滤、输出格式及文件轮转
+type SlogHandler struct {
+	attrs  []Field
+	groups string //当前分组前缀, 如 "req.user."
+}
+
+// NewSlogHandler 创建slog处理器
+func NewSlogHandler() *SlogHandler {
+	return &SlogHandler{}
+}
+
+// SetSlogDefault 把gmf日志设为slog的默认输出, 标准库log的输出也会一并转入
+func SetSlogDefault() {
+	//带文件标志时slog才会为标准库log的输出采集调用位置
+	log.SetFlags(log.Lshortfile)
+	slog.SetDefault(slog.New(NewSlogHandler()))
+}
+
+// slogLevel 把slog级别映射到gmf级别
+func slogLevel(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return LOG_LEVEL_DEBUG
+	case level < slog.LevelWarn:
+		return LOG_LEVEL_INFO
+	case level < slog.LevelError:
+		return LOG_LEVEL_WARNING
+	}
+	return LOG_LEVEL_ERROR
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return std().enabled(slogLevel(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	unit := &Entry{
+		Level:   slogLevel(r.Level),
+		LogStr:  r.Message,
+		AddTime: r.Time,
+	}
+	if unit.AddTime.IsZero() {
+		unit.AddTime = time.Now()
+	}
+	if !sNoCaller.Load() {
+		unit.pc = r.PC
+	}
+	l := std()
+	if !l.allow(unit) {
+		return nil
+	}
+	cfs := ContextFields(ctx)
+	fields := make([]Field, 0, len(cfs)+len(h.attrs)+r.NumAttrs())
+	fields = append(fields, cfs...)
+	fields = append(fields, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.groups, a)
+		return true
+	})
+	if len(fields) > 0 {
+		unit.Fields = fields
+	}
+	l.formatWriteLogMsg(unit)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := &SlogHandler{groups: h.groups}
+	h2.attrs = make([]Field, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.groups, a)
+	}
+	return h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{attrs: h.attrs, groups: h.groups + name + "."}
+}
+
+// appendAttr 展开分组, 键名以点连接, 如 req.method
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	return append(fields, Field{Key: prefix + a.Key, Value: a.Value.Any()})
+}
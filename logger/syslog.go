@@ -0,0 +1,43 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"bytes"
+	"log/syslog"
+)
+
+// SyslogSink 写本机syslog(unix socket), 级别映射为 debug / info / warning / err
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink 连接本机syslog, tag 为空时使用程序名
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+func (s *SyslogSink) Write(e *Entry, line []byte) error {
+	msg := string(bytes.TrimRight(line, "\n"))
+	switch e.Level {
+	case LOG_LEVEL_DEBUG:
+		return s.w.Debug(msg)
+	case LOG_LEVEL_INFO:
+		return s.w.Info(msg)
+	case LOG_LEVEL_WARNING:
+		return s.w.Warning(msg)
+	}
+	return s.w.Err(msg)
+}
+
+func (s *SyslogSink) Flush() error {
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
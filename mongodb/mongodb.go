@@ -12,20 +12,34 @@ import (
 	"time"
 )
 
+// Client 一个mongodb实例的连接参数, 每次操作按需建立连接
+type Client struct {
+	clientOptions *options.ClientOptions
+}
+
 var (
-	gClientOptions *options.ClientOptions = nil
+	gClient = &Client{}
 )
 
-func Init(dbhost, authdb, authuser, authpass string) {
+func newClientOptions(dbhost, authdb, authuser, authpass string) *options.ClientOptions {
 	url := fmt.Sprintf("mongodb://%s:%s@%s/%s", authuser, url.QueryEscape(authpass), dbhost, authdb)
-	logger.LOGD("url:", url)
-	gClientOptions = options.Client().ApplyURI(url)
+	logger.LOGD("url:", logger.Redact(url))
+	return options.Client().ApplyURI(url)
+}
+
+func Init(dbhost, authdb, authuser, authpass string) {
+	gClient.clientOptions = newClientOptions(dbhost, authdb, authuser, authpass)
 }
 
-func connect(db, collection string) (*mongo.Client, *mongo.Collection) {
+// NewClient 创建一个独立的mongodb实例
+func NewClient(dbhost, authdb, authuser, authpass string) *Client {
+	return &Client{clientOptions: newClientOptions(dbhost, authdb, authuser, authpass)}
+}
+
+func (m *Client) connect(db, collection string) (*mongo.Client, *mongo.Collection) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	client, err := mongo.Connect(ctx, gClientOptions)
+	client, err := mongo.Connect(ctx, m.clientOptions)
 	if err != nil {
 		logger.LOGE("err:", err)
 		return nil, nil
@@ -47,8 +61,8 @@ func close(client *mongo.Client) {
 	}
 }
 
-func Count(db, collection string, query interface{}) (int, error) {
-	ms, c := connect(db, collection)
+func (m *Client) Count(db, collection string, query interface{}) (int, error) {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -56,8 +70,8 @@ func Count(db, collection string, query interface{}) (int, error) {
 	return int(count), err
 }
 
-func Insert(db, collection string, docs ...interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) Insert(db, collection string, docs ...interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	mdocs := make([]interface{}, 0)
@@ -68,8 +82,8 @@ func Insert(db, collection string, docs ...interface{}) error {
 	return err
 }
 
-func FindOne(db, collection string, query, selector, result interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) FindOne(db, collection string, query, selector, result interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if query == nil {
@@ -87,8 +101,8 @@ func FindOne(db, collection string, query, selector, result interface{}) error {
 	return res.Decode(result)
 }
 
-func FindAll(db, collection string, query, selector, result interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) FindAll(db, collection string, query, selector, result interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if query == nil {
@@ -104,8 +118,8 @@ func FindAll(db, collection string, query, selector, result interface{}) error {
 	return cur.All(ctx, result)
 }
 
-func FindSortByCount(db, collection string, limit int, field string, query, result interface{}) (int, error) {
-	ms, c := connect(db, collection)
+func (m *Client) FindSortByCount(db, collection string, limit int, field string, query, result interface{}) (int, error) {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if query == nil {
@@ -116,10 +130,10 @@ func FindSortByCount(db, collection string, limit int, field string, query, resu
 	defer cancel()
 	opts := options.Find()
 	if []byte(field)[0] == '-' {
-		SORT := bson.D{{string([]byte(field)[1:]), -1}}
+		SORT := bson.D{{Key: string([]byte(field)[1:]), Value: -1}}
 		opts.SetSort(SORT)
 	} else {
-		SORT := bson.D{{field, 1}}
+		SORT := bson.D{{Key: field, Value: 1}}
 		opts.SetSort(SORT)
 	}
 	opts.SetLimit(int64(limit))
@@ -131,8 +145,8 @@ func FindSortByCount(db, collection string, limit int, field string, query, resu
 	return 0, cur.All(ctx, result)
 }
 
-func Update(db, collection string, selector, update interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) Update(db, collection string, selector, update interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if selector == nil {
@@ -147,8 +161,8 @@ func Update(db, collection string, selector, update interface{}) error {
 	return err
 }
 
-func UpdateAll(db, collection string, selector, update interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) UpdateAll(db, collection string, selector, update interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if selector == nil {
@@ -163,8 +177,8 @@ func UpdateAll(db, collection string, selector, update interface{}) error {
 	return err
 }
 
-func Remove(db, collection string, selector interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) Remove(db, collection string, selector interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if selector == nil {
@@ -178,8 +192,8 @@ func Remove(db, collection string, selector interface{}) error {
 	return err
 }
 
-func RemoveAll(db, collection string, selector interface{}) error {
-	ms, c := connect(db, collection)
+func (m *Client) RemoveAll(db, collection string, selector interface{}) error {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 
 	if selector == nil {
@@ -193,8 +207,8 @@ func RemoveAll(db, collection string, selector interface{}) error {
 	return err
 }
 
-func GetNextSequence(db, collection, name string) (int64, bool) {
-	ms, c := connect(db, collection)
+func (m *Client) GetNextSequence(db, collection, name string) (int64, bool) {
+	ms, c := m.connect(db, collection)
 	defer close(ms)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -213,3 +227,43 @@ func GetNextSequence(db, collection, name string) (int64, bool) {
 	}
 	return seq.Value + 1, true
 }
+
+func Count(db, collection string, query interface{}) (int, error) {
+	return defaultClient().Count(db, collection, query)
+}
+
+func Insert(db, collection string, docs ...interface{}) error {
+	return defaultClient().Insert(db, collection, docs...)
+}
+
+func FindOne(db, collection string, query, selector, result interface{}) error {
+	return defaultClient().FindOne(db, collection, query, selector, result)
+}
+
+func FindAll(db, collection string, query, selector, result interface{}) error {
+	return defaultClient().FindAll(db, collection, query, selector, result)
+}
+
+func FindSortByCount(db, collection string, limit int, field string, query, result interface{}) (int, error) {
+	return defaultClient().FindSortByCount(db, collection, limit, field, query, result)
+}
+
+func Update(db, collection string, selector, update interface{}) error {
+	return defaultClient().Update(db, collection, selector, update)
+}
+
+func UpdateAll(db, collection string, selector, update interface{}) error {
+	return defaultClient().UpdateAll(db, collection, selector, update)
+}
+
+func Remove(db, collection string, selector interface{}) error {
+	return defaultClient().Remove(db, collection, selector)
+}
+
+func RemoveAll(db, collection string, selector interface{}) error {
+	return defaultClient().RemoveAll(db, collection, selector)
+}
+
+func GetNextSequence(db, collection, name string) (int64, bool) {
+	return defaultClient().GetNextSequence(db, collection, name)
+}
@@ -1,12 +1,45 @@
 package mongodb
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/wyy8261/gmf/conf"
 )
 
-func init() {
-	var (
-		mgConf = &conf.Default().Mongo
-	)
-	Init(mgConf.Addr(), mgConf.DBName, mgConf.User, mgConf.Pwd)
+var (
+	gClientOnce  sync.Once
+	gNamedClient = make(map[string]*Client)
+	gNamedMutex  sync.Mutex
+)
+
+// defaultClient 默认客户端, 未调用 Init 时第一次使用按 conf.Default() 初始化, 以便 main 先调用 conf.Load
+func defaultClient() *Client {
+	gClientOnce.Do(func() {
+		if gClient.clientOptions == nil {
+			mgConf := &conf.Default().Mongo
+			Init(mgConf.Addr(), mgConf.DBName, mgConf.User, mgConf.Pwd)
+		}
+	})
+	return gClient
+}
+
+// Named 返回具名实例 [Mongo.name] 的客户端, name为空时返回默认客户端
+func Named(name string) (*Client, error) {
+	if name == "" {
+		return defaultClient(), nil
+	}
+
+	gNamedMutex.Lock()
+	defer gNamedMutex.Unlock()
+	if c, ok := gNamedClient[name]; ok {
+		return c, nil
+	}
+	mgConf, ok := conf.Default().Server("Mongo", name)
+	if !ok {
+		return nil, fmt.Errorf("mongo instance %q not configured", name)
+	}
+	c := NewClient(mgConf.Addr(), mgConf.DBName, mgConf.User, mgConf.Pwd)
+	gNamedClient[name] = c
+	return c, nil
 }
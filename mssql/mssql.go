@@ -15,6 +15,7 @@ import (
 	//_ "github.com/alexbrainman/odbc"
 	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/mitchellh/mapstructure"
+	"github.com/wyy8261/gmf/logger"
 )
 
 // ProcArgs 储存过程参数
@@ -104,7 +105,7 @@ func (m *Mssql) Open() (err error) {
 	conf = append(conf, "password="+m.sa.passwd)
 	conf = append(conf, "encrypt=disable")
 
-	log.Println("open:", strings.Join(conf, ";"))
+	log.Println("open:", logger.Redact(strings.Join(conf, ";")))
 	m.DB, err = sql.Open("sqlserver", strings.Join(conf, ";"))
 	//设置最大空闲连接数
 	m.DB.SetMaxIdleConns(20)
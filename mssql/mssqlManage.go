@@ -1,50 +1,86 @@
 package mssql
 
 import (
+	"fmt"
 	"github.com/wyy8261/gmf/conf"
 	"github.com/wyy8261/gmf/logger"
 	"strconv"
+	"sync"
 )
 
 var (
-	gMspool *Mssql = nil
+	gMspool     *Mssql = nil
+	gMspoolOnce sync.Once
+	gNamedPool  = make(map[string]*Mssql)
+	gNamedMutex sync.Mutex
 )
 
-func init() {
-	var (
-		err    error
-		msConf = &conf.Default().Mssql
-	)
-	gMspool, err = Init(msConf.IP, strconv.Itoa(msConf.Port), msConf.DBName, msConf.User, msConf.Pwd)
+// defaultPool 默认连接池, 第一次使用时按 conf.Default() 建立, 以便 main 先调用 conf.Load
+func defaultPool() *Mssql {
+	gMspoolOnce.Do(func() {
+		var (
+			err    error
+			msConf = &conf.Default().Mssql
+		)
+		gMspool, err = Init(msConf.IP, strconv.Itoa(msConf.Port), msConf.DBName, msConf.User, msConf.Pwd)
+		if err != nil {
+			logger.LOGE("err:", err)
+		}
+	})
+	return gMspool
+}
+
+// Pool 返回具名实例 [Mssql.name] 的连接池, 第一次使用时建立; name为空时返回默认连接池
+func Pool(name string) (*Mssql, error) {
+	if name == "" {
+		ms := defaultPool()
+		if ms == nil {
+			return nil, fmt.Errorf("mssql default pool not initialized")
+		}
+		return ms, nil
+	}
+
+	gNamedMutex.Lock()
+	defer gNamedMutex.Unlock()
+	if ms, ok := gNamedPool[name]; ok {
+		return ms, nil
+	}
+	msConf, ok := conf.Default().Server("Mssql", name)
+	if !ok {
+		return nil, fmt.Errorf("mssql instance %q not configured", name)
+	}
+	ms, err := Init(msConf.IP, strconv.Itoa(msConf.Port), msConf.DBName, msConf.User, msConf.Pwd)
 	if err != nil {
-		logger.LOGE("err:", err)
+		return nil, err
 	}
+	gNamedPool[name] = ms
+	return ms, nil
 }
 
 func StoredProcedureBySprint(sSQL string, a ...interface{}) (*MssqlResult, error) {
-	if gMspool != nil {
-		return gMspool.StoredProcedureBySprint(sSQL, a...)
+	if ms := defaultPool(); ms != nil {
+		return ms.StoredProcedureBySprint(sSQL, a...)
 	}
 	return nil, nil
 }
 
 func QueryBySprint(sSQL string, a ...interface{}) (*MssqlResult, error) {
-	if gMspool != nil {
-		return gMspool.QueryBySprint(sSQL, a...)
+	if ms := defaultPool(); ms != nil {
+		return ms.QueryBySprint(sSQL, a...)
 	}
 	return nil, nil
 }
 
 func Execute(sSQL string) error {
-	if gMspool != nil {
-		return gMspool.Execute(sSQL)
+	if ms := defaultPool(); ms != nil {
+		return ms.Execute(sSQL)
 	}
 	return nil
 }
 
 func Query(sSQL string) (*MssqlResult, error) {
-	if gMspool != nil {
-		return gMspool.Query(sSQL)
+	if ms := defaultPool(); ms != nil {
+		return ms.Query(sSQL)
 	}
 	return nil, nil
 }
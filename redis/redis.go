@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/wyy8261/gmf/logger"
@@ -15,26 +16,53 @@ import (
 )
 
 var (
-	ctx    = context.Background()
-	client *rds.Client
+	ctx          = context.Background()
+	client       *rds.Client
+	namedClients = make(map[string]*rds.Client)
+	namedMutex   sync.Mutex
 )
 
+func newClient(cfg conf.ServerInfo) *rds.Client {
+	return rds.NewClient(&rds.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Pwd,
+		DB:       util.Atoi(cfg.DBName),
+		PoolSize: 100,
+	})
+}
+
 func Client() *rds.Client {
 	if client != nil {
 		return client
 	}
 
 	cfg := conf.Default().Redis
-	client = rds.NewClient(&rds.Options{
-		Addr:     cfg.Addr(),
-		Password: cfg.Pwd,
-		DB:       util.Atoi(cfg.DBName),
-		PoolSize: 100,
-	})
+	client = newClient(cfg)
 	logger.LOGD("addr:", cfg.Addr())
 	return client
 }
 
+// ClientOf 返回具名实例 [Redis.name] 的客户端, name为空时返回默认客户端
+func ClientOf(name string) (*rds.Client, error) {
+	if name == "" {
+		return Client(), nil
+	}
+
+	namedMutex.Lock()
+	defer namedMutex.Unlock()
+	if c, ok := namedClients[name]; ok {
+		return c, nil
+	}
+	cfg, ok := conf.Default().Server("Redis", name)
+	if !ok {
+		return nil, fmt.Errorf("redis instance %q not configured", name)
+	}
+	c := newClient(cfg)
+	namedClients[name] = c
+	logger.LOGD("name:", name, ",addr:", cfg.Addr())
+	return c, nil
+}
+
 /* -------------------- 工具函数 -------------------- */
 
 func normalizeValue(v interface{}) string {
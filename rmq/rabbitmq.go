@@ -65,6 +65,7 @@ type RabbitMQ struct {
 	reconnChan  chan struct{}
 	queueManage QueueManage
 	db          *gorm.DB
+	dbFile      string //发送失败消息的本地缓存文件, 为空时使用 gorm.db
 	errNum      int
 	url         string
 	producerOn  bool
@@ -325,7 +326,11 @@ func (r *RabbitMQ) ensureProducer() error {
 	r.queueManage.stop = false
 	r.queueManage.rouse = make(chan struct{}, 1)
 
-	db, err := gorm.Open(sqlite.Open("gorm.db"), &gorm.Config{})
+	dbFile := r.dbFile
+	if dbFile == "" {
+		dbFile = "gorm.db"
+	}
+	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
 	if err != nil {
 		logger.LOGE("err:", err)
 		return err
@@ -553,23 +558,39 @@ func (c *Subscription) consumerWork() {
 					goto RESTART
 				}
 
-				message := &Message{
-					Topic: c.queue,
-					Key:   msg.RoutingKey,
-					Data:  string(msg.Body),
-				}
-				if err := c.handler(c.ctx, message); err != nil {
-					logger.LOGE("error", "consumer error", c.queue, msg.RoutingKey, msg.Body, err)
-				}
-				if err := msg.Ack(false); err != nil {
-					logger.LOGE("ack error", c.queue, msg.RoutingKey, err)
-				}
+				c.process(msg)
 			}
 		}
 	RESTART:
 	}
 }
 
+// process 处理一条消息, 成功后确认; 失败或panic时拒绝,
+// 第一次失败重新入队, 重投后仍失败则不再入队(队列配置了死信交换机时转入死信队列), 避免一直重试
+func (c *Subscription) process(msg amqp.Delivery) {
+	message := &Message{
+		Topic: c.queue,
+		Key:   msg.RoutingKey,
+		Data:  string(msg.Body),
+	}
+	if err := c.handle(message); err != nil {
+		logger.LOGE("error", "consumer error", c.queue, msg.RoutingKey, msg.Body, err)
+		if err := msg.Nack(false, !msg.Redelivered); err != nil {
+			logger.LOGE("nack error", c.queue, msg.RoutingKey, err)
+		}
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		logger.LOGE("ack error", c.queue, msg.RoutingKey, err)
+	}
+}
+
+// handle 调用回调, 回调中的panic记录堆栈后作为错误返回
+func (c *Subscription) handle(message *Message) (err error) {
+	defer logger.RecoverErr(&err)
+	return c.handler(c.ctx, message)
+}
+
 func (c *Subscription) Cancel() error {
 	c.startMu.Lock()
 	defer c.startMu.Unlock()
@@ -0,0 +1,53 @@
+package rmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+// ackRecorder 记录消息的确认结果
+type ackRecorder struct {
+	acks, nacks, requeues int
+}
+
+func (a *ackRecorder) Ack(tag uint64, multiple bool) error {
+	a.acks++
+	return nil
+}
+
+func (a *ackRecorder) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacks++
+	if requeue {
+		a.requeues++
+	}
+	return nil
+}
+
+func (a *ackRecorder) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestSubscriptionProcess(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		handler     Handler
+		redelivered bool
+		want        ackRecorder
+	}{
+		{"ok", func(context.Context, *Message) error { return nil }, false, ackRecorder{acks: 1}},
+		{"error", func(context.Context, *Message) error { return errors.New("failed") }, false, ackRecorder{nacks: 1, requeues: 1}},
+		{"panic", func(context.Context, *Message) error { panic("boom") }, false, ackRecorder{nacks: 1, requeues: 1}},
+		//重投后仍失败不再入队
+		{"panic redelivered", func(context.Context, *Message) error { panic("boom") }, true, ackRecorder{nacks: 1}},
+	} {
+		sub := &Subscription{queue: "test", handler: c.handler, ctx: context.Background()}
+		rec := &ackRecorder{}
+		sub.process(amqp.Delivery{Acknowledger: rec, Body: []byte("data"), Redelivered: c.redelivered})
+		if *rec != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, *rec, c.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package rmq
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/wyy8261/gmf/conf"
@@ -9,44 +11,64 @@ import (
 )
 
 var (
-	producerClient *RabbitMQ
-	consumerClient *RabbitMQ
+	producerClients = make(map[string]*RabbitMQ)
+	consumerClients = make(map[string]*RabbitMQ)
+	clientMutex     sync.Mutex
 )
 
-func defaultRabbitMQConfig() *conf.ServerInfo {
-	cfg := conf.Default().RabbitMQ
-	return &cfg
+// rabbitMQConfig 返回具名实例 [RabbitMQ.name] 的配置, name为空时返回默认配置
+func rabbitMQConfig(name string) (*conf.ServerInfo, error) {
+	cfg, ok := conf.Default().Server("RabbitMQ", name)
+	if !ok {
+		return nil, fmt.Errorf("rabbitmq instance %q not configured", name)
+	}
+	return &cfg, nil
 }
 
-func ensureSender() (*RabbitMQ, error) {
-	if producerClient != nil {
-		return producerClient, nil
+func ensureClient(clients map[string]*RabbitMQ, name string) (*RabbitMQ, error) {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+	if client, ok := clients[name]; ok {
+		return client, nil
 	}
 
-	client, err := NewRabbitMQ(defaultRabbitMQConfig())
+	cfg, err := rabbitMQConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewRabbitMQ(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if name != "" {
+		//具名实例各自缓存发送失败的消息, 避免重发到其它实例
+		client.dbFile = "gorm." + name + ".db"
+	}
 
-	producerClient = client
-	return producerClient, nil
+	clients[name] = client
+	return client, nil
 }
 
-func ensureConsumer() (*RabbitMQ, error) {
-	if consumerClient != nil {
-		return consumerClient, nil
-	}
+func ensureSender(name string) (*RabbitMQ, error) {
+	return ensureClient(producerClients, name)
+}
 
-	client, err := NewRabbitMQ(defaultRabbitMQConfig())
-	if err != nil {
-		return nil, err
-	}
+func ensureConsumer(name string) (*RabbitMQ, error) {
+	return ensureClient(consumerClients, name)
+}
 
-	consumerClient = client
-	return consumerClient, nil
+// Sender 返回具名实例的生产者连接, name为空时为默认实例
+func Sender(name string) (*RabbitMQ, error) {
+	return ensureSender(name)
 }
+
+// Consumer 返回具名实例的消费者连接, name为空时为默认实例
+func Consumer(name string) (*RabbitMQ, error) {
+	return ensureConsumer(name)
+}
+
 func PostQueue(exchangeName, routingKey, message string, delayTime ...time.Duration) {
-	client, err := ensureSender()
+	client, err := ensureSender("")
 	if err != nil {
 		logger.LOGE("StartRmq:", err)
 		return
@@ -56,7 +78,7 @@ func PostQueue(exchangeName, routingKey, message string, delayTime ...time.Durat
 
 // 外部使用
 func RegisterConsumer(queueName string, callback Handler) *Subscription {
-	client, err := ensureConsumer()
+	client, err := ensureConsumer("")
 	if err != nil {
 		logger.LOGE("RegisterRMQ:", err)
 		return nil
@@ -73,7 +95,7 @@ func RegisterConsumer(queueName string, callback Handler) *Subscription {
 }
 
 func GetMessage(queueName string, size int, timeout ...time.Duration) (error, []string) {
-	client, err := ensureConsumer()
+	client, err := ensureConsumer("")
 	if err != nil {
 		return err, nil
 	}
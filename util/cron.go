@@ -0,0 +1,232 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计算任务的下次运行时间, 返回零值表示不再运行
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type intervalSchedule time.Duration
+
+// IntervalSchedule 固定间隔, 第一次运行在一个间隔之后
+func IntervalSchedule(d time.Duration) Schedule {
+	if d <= 0 {
+		d = time.Second
+	}
+	return intervalSchedule(d)
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule 各字段允许的取值, 按位保存
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool //日期或星期为 * 时两者取交集, 否则取并集(与crontab一致)
+	loc                                   *time.Location
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSecond = cronField{0, 59, nil}
+	cronMinute = cronField{0, 59, nil}
+	cronHour   = cronField{0, 23, nil}
+	cronDom    = cronField{1, 31, nil}
+	cronMonth  = cronField{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseCron 解析cron表达式, 按本地时区计算:
+//
+//	分 时 日 月 星期            如 "*/5 * * * *"
+//	秒 分 时 日 月 星期         如 "30 0 3 * * mon-fri"
+//	@yearly @monthly @weekly @daily @hourly
+//	@every 1h30m               固定间隔
+//	CRON_TZ=Asia/Shanghai 0 3 * * *
+//
+// 每个字段支持 * ? a-b */n a-b/n a/n 及逗号分隔的列表, 月份和星期可以用英文缩写, 星期的0和7都是周日
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	loc := time.Local
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tz, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(tz, "=")
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("cron: %q: %w", spec, err)
+		}
+		spec = strings.TrimSpace(rest)
+	}
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("cron: invalid interval %q", spec)
+		}
+		return IntervalSchedule(d), nil
+	}
+	if s, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron: %q: want 5 or 6 fields, got %d", spec, len(fields))
+	}
+	s := &cronSchedule{loc: loc}
+	var err error
+	for i, p := range []struct {
+		dst   *uint64
+		field cronField
+	}{
+		{&s.second, cronSecond}, {&s.minute, cronMinute}, {&s.hour, cronHour},
+		{&s.dom, cronDom}, {&s.month, cronMonth}, {&s.dow, cronDow},
+	} {
+		if *p.dst, err = parseCronField(fields[i], p.field); err != nil {
+			return nil, fmt.Errorf("cron: %q: %w", spec, err)
+		}
+	}
+	//星期7与0同为周日
+	if s.dow&(1<<7) > 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[3] == "*" || fields[3] == "?"
+	s.dowStar = fields[5] == "*" || fields[5] == "?"
+	return s, nil
+}
+
+func parseCronField(expr string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			if !hasStep {
+				hi = lo
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %q out of range [%d, %d]", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next t之后第一个匹配的时间, 5年内没有匹配时返回零值。
+// 按墙上时间匹配: 夏令时跳过的时刻不运行, 回拨后重复的时刻只在第一次运行
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	lt := t.In(s.loc)
+	//在UTC中按墙上时间查找, 不受时区切换影响
+	w := time.Date(lt.Year(), lt.Month(), lt.Day(), lt.Hour(), lt.Minute(), lt.Second(), 0, time.UTC)
+	limit := w.Year() + 5
+	for {
+		if w = s.nextWall(w, limit); w.IsZero() {
+			return w
+		}
+		y, mon, d := w.Date()
+		h, mi, sec := w.Clock()
+		next := time.Date(y, mon, d, h, mi, sec, 0, s.loc)
+		if next.Hour() == h && next.Minute() == mi && next.After(t) {
+			return next.In(t.Location())
+		}
+	}
+}
+
+// nextWall w之后第一个匹配的墙上时间, w及返回值都在UTC中表示
+func (s *cronSchedule) nextWall(w time.Time, limit int) time.Time {
+	t := w.Add(time.Second)
+	for t.Year() <= limit {
+		y, mon, d := t.Date()
+		h, mi, sec := t.Clock()
+		switch {
+		case s.month&(1<<uint(mon)) == 0:
+			t = time.Date(y, mon+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatch(t):
+			t = time.Date(y, mon, d+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(h)) == 0:
+			t = time.Date(y, mon, d, h+1, 0, 0, 0, time.UTC)
+		case s.minute&(1<<uint(mi)) == 0:
+			t = time.Date(y, mon, d, h, mi+1, 0, 0, time.UTC)
+		case s.second&(1<<uint(sec)) == 0:
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatch(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) > 0
+	dow := s.dow&(1<<uint(t.Weekday())) > 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,132 @@
+package util
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestCronNext(t *testing.T) {
+	utc := func(y int, mon time.Month, d, h, mi, sec int) time.Time {
+		return time.Date(y, mon, d, h, mi, sec, 0, time.UTC)
+	}
+	//2024-01-31 为周三, 2024年2月有29天
+	for _, c := range []struct {
+		spec       string
+		from, want time.Time
+	}{
+		//5个字段及6个字段
+		{"*/15 * * * *", utc(2024, 1, 31, 10, 7, 30), utc(2024, 1, 31, 10, 15, 0)},
+		{"0 9-17/4 * * *", utc(2024, 1, 31, 9, 0, 0), utc(2024, 1, 31, 13, 0, 0)},
+		{"30 0 3 * * *", utc(2024, 1, 31, 3, 0, 30), utc(2024, 2, 1, 3, 0, 30)},
+		{"*/10 * * * * *", utc(2024, 1, 31, 10, 7, 30), utc(2024, 1, 31, 10, 7, 40)},
+		//描述符及固定间隔
+		{"@hourly", utc(2024, 1, 31, 10, 7, 30), utc(2024, 1, 31, 11, 0, 0)},
+		{"@daily", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 1, 0, 0, 0)},
+		{"@weekly", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 4, 0, 0, 0)},
+		{"@monthly", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 1, 0, 0, 0)},
+		{"@yearly", utc(2024, 1, 31, 12, 0, 0), utc(2025, 1, 1, 0, 0, 0)},
+		{"@every 90m", utc(2024, 1, 31, 10, 0, 0), utc(2024, 1, 31, 11, 30, 0)},
+		//月份及星期的英文缩写
+		{"0 9 * jan-mar mon,wed", utc(2024, 3, 29, 10, 0, 0), utc(2025, 1, 1, 9, 0, 0)},
+		{"0 0 * * SUN", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 4, 0, 0, 0)},
+		//星期的0和7都是周日
+		{"0 0 * * 0", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 4, 0, 0, 0)},
+		{"0 0 * * 7", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 4, 0, 0, 0)},
+		{"0 0 * * 5-7", utc(2024, 2, 3, 12, 0, 0), utc(2024, 2, 4, 0, 0, 0)},
+		//日期和星期都有限制时取并集, 其中一个为 * 时只按另一个
+		{"0 0 13 * fri", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 2, 0, 0, 0)},
+		{"0 0 13 * fri", utc(2024, 2, 10, 12, 0, 0), utc(2024, 2, 13, 0, 0, 0)},
+		{"0 0 13 * *", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 13, 0, 0, 0)},
+		{"0 0 * * fri", utc(2024, 2, 10, 12, 0, 0), utc(2024, 2, 16, 0, 0, 0)},
+		{"0 0 13 ? *", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 13, 0, 0, 0)},
+		//跨月末及年末
+		{"0 0 1 * *", utc(2024, 1, 31, 12, 0, 0), utc(2024, 2, 1, 0, 0, 0)},
+		{"0 0 31 * *", utc(2024, 1, 31, 12, 0, 0), utc(2024, 3, 31, 0, 0, 0)},
+		{"0 0 30 * *", utc(2024, 1, 31, 12, 0, 0), utc(2024, 3, 30, 0, 0, 0)},
+		{"0 0 29 2 *", utc(2024, 3, 1, 0, 0, 0), utc(2028, 2, 29, 0, 0, 0)},
+		{"59 23 31 12 *", utc(2024, 12, 31, 23, 59, 0), utc(2025, 12, 31, 23, 59, 0)},
+		{"0 0 1 1 *", utc(2024, 12, 31, 12, 0, 0), utc(2025, 1, 1, 0, 0, 0)},
+		//不存在的日期
+		{"0 0 30 2 *", utc(2024, 1, 31, 12, 0, 0), time.Time{}},
+	} {
+		s, err := ParseCron("CRON_TZ=UTC " + c.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		if got := s.Next(c.from); !got.Equal(c.want) {
+			t.Errorf("%q from %v: got %v, want %v", c.spec, c.from, got, c.want)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(mon time.Month, d, h, mi int) time.Time {
+		return time.Date(2024, mon, d, h, mi, 0, 0, ny)
+	}
+	//2024-03-10 02:00 EST 跳到 03:00 EDT, 2024-11-03 02:00 EDT 回到 01:00 EST
+	for _, c := range []struct {
+		spec       string
+		from, want time.Time
+	}{
+		{"0 * * * *", at(3, 10, 1, 30), at(3, 10, 3, 0)},
+		{"30 2 * * *", at(3, 9, 3, 0), at(3, 11, 2, 30)}, //当天没有 02:30, 不会在其他时刻补跑
+		{"0 3 * * *", at(3, 9, 3, 0), at(3, 10, 3, 0)},
+		{"0 3 * * *", at(11, 2, 3, 0), at(11, 3, 3, 0)},
+		{"0 12 * * *", at(11, 2, 12, 0), at(11, 3, 12, 0)},
+		//回拨后重复的 01:xx 只运行一次
+		{"30 1 * * *", at(11, 3, 0, 0), at(11, 3, 1, 30)},
+		{"30 1 * * *", at(11, 3, 1, 30), at(11, 4, 1, 30)},
+		{"0 * * * *", at(11, 3, 1, 0), at(11, 3, 2, 0)},
+	} {
+		s, err := ParseCron("CRON_TZ=America/New_York " + c.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		got := s.Next(c.from)
+		if !got.Equal(c.want) {
+			t.Errorf("%q from %v: got %v, want %v", c.spec, c.from, got, c.want)
+		}
+		//返回值使用参数的时区
+		if got.Location() != ny {
+			t.Errorf("%q: location %v", c.spec, got.Location())
+		}
+	}
+	//跨越切换的间隔按实际经过的时间计算
+	s, _ := ParseCron("CRON_TZ=America/New_York 0 3 * * *")
+	if d := s.Next(at(3, 9, 3, 0)).Sub(at(3, 9, 3, 0)); d != 23*time.Hour {
+		t.Errorf("spring forward: %v", d)
+	}
+	if d := s.Next(at(11, 2, 3, 0)).Sub(at(11, 2, 3, 0)); d != 25*time.Hour {
+		t.Errorf("fall back: %v", d)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every",
+		"@every -1s",
+		"@every x",
+		"CRON_TZ=Nowhere/Nothing * * * * *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	rand2 "crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 信封格式: 标识"GMFE"(4字节) | 版本(1字节) | 密钥ID长度(1字节) | 密钥ID | nonce(12字节) | AES-GCM密文及tag(16字节)
+// 标识、版本和密钥ID作为附加数据参与认证, 不能被篡改; 多字节标识避免旧版 AES/ECB 密文被误认为信封格式
+const (
+	ENVELOPE_MAGIC   = "GMFE"
+	ENVELOPE_VERSION = 1
+	ENVELOPE_NONCE   = 12
+	ENVELOPE_TAG     = 16
+)
+
+var (
+	ErrNoKey         = errors.New("util: no aes key")
+	ErrUnknownKey    = errors.New("util: unknown key id")
+	ErrBadEnvelope   = errors.New("util: malformed envelope")
+	ErrDecryptFailed = errors.New("util: decrypt failed")
+)
+
+// KeyRing 按ID管理多个AES密钥, 用于密钥轮换:
+// 加密使用主密钥, 解密按信封中的密钥ID选择密钥, 非信封格式的数据用旧版密钥按 AES/ECB 解密
+type KeyRing struct {
+	mutex   sync.RWMutex
+	keys    map[string]cipher.AEAD
+	raw     map[string][]byte
+	primary string
+	legacy  string
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		keys: make(map[string]cipher.AEAD),
+		raw:  make(map[string][]byte),
+	}
+}
+
+// Add 添加密钥, key 须为16/24/32字节; 第一个添加的密钥同时作为主密钥和旧版密钥
+func (r *KeyRing) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("util: invalid key id %q", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.keys[id] = aead
+	r.raw[id] = append([]byte(nil), key...)
+	if r.primary == "" {
+		r.primary = id
+		r.legacy = id
+	}
+	return nil
+}
+
+// Remove 移除密钥, 之后用该密钥加密的数据无法解密
+func (r *KeyRing) Remove(id string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.keys, id)
+	delete(r.raw, id)
+	if r.primary == id {
+		r.primary = ""
+	}
+	if r.legacy == id {
+		r.legacy = ""
+	}
+}
+
+// SetPrimary 设置加密使用的密钥
+func (r *KeyRing) SetPrimary(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.keys[id]; !ok {
+		return ErrUnknownKey
+	}
+	r.primary = id
+	return nil
+}
+
+// SetLegacy 设置解密旧版 AES/ECB 数据的密钥, 为空时不再接受旧版数据
+func (r *KeyRing) SetLegacy(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.keys[id]; !ok && id != "" {
+		return ErrUnknownKey
+	}
+	r.legacy = id
+	return nil
+}
+
+// Primary 当前主密钥ID
+func (r *KeyRing) Primary() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.primary
+}
+
+// Encrypt 用主密钥加密为信封格式
+func (r *KeyRing) Encrypt(src []byte) ([]byte, error) {
+	r.mutex.RLock()
+	id := r.primary
+	aead := r.keys[id]
+	r.mutex.RUnlock()
+	if aead == nil {
+		return nil, ErrNoKey
+	}
+
+	header := append(append([]byte(ENVELOPE_MAGIC), ENVELOPE_VERSION, byte(len(id))), id...)
+	nonce := make([]byte, ENVELOPE_NONCE)
+	if _, err := rand2.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst := make([]byte, 0, len(header)+ENVELOPE_NONCE+len(src)+ENVELOPE_TAG)
+	dst = append(append(dst, header...), nonce...)
+	return aead.Seal(dst, nonce, src, header), nil
+}
+
+// Decrypt 解密信封格式的数据; 不是信封格式时, 若设置了旧版密钥则按 AES/ECB 解密。
+// 信封格式但密钥ID未知或认证失败时直接返回错误, 不再尝试没有认证的 AES/ECB
+func (r *KeyRing) Decrypt(src []byte) ([]byte, error) {
+	plain, err := r.open(src)
+	if err != ErrBadEnvelope {
+		return plain, err
+	}
+	r.mutex.RLock()
+	key := r.raw[r.legacy]
+	r.mutex.RUnlock()
+	if key == nil {
+		return nil, err
+	}
+	if plain, lerr := AesDecrypt(src, key); lerr == nil {
+		return plain, nil
+	}
+	return nil, err
+}
+
+func (r *KeyRing) open(src []byte) ([]byte, error) {
+	id, nonce, data, err := parseEnvelope(src)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.RLock()
+	aead := r.keys[id]
+	r.mutex.RUnlock()
+	if aead == nil {
+		return nil, ErrUnknownKey
+	}
+	plain, err := aead.Open(nil, nonce, data, src[:envelopeHeader+len(id)])
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	return plain, nil
+}
+
+// IsEnvelope 数据是否为信封格式(只检查格式, 不验证密文)
+func IsEnvelope(src []byte) bool {
+	_, _, _, err := parseEnvelope(src)
+	return err == nil
+}
+
+// EnvelopeKeyId 信封中的密钥ID
+func EnvelopeKeyId(src []byte) (string, error) {
+	id, _, _, err := parseEnvelope(src)
+	return id, err
+}
+
+// envelopeHeader 密钥ID之前的固定长度: 标识、版本及密钥ID长度
+const envelopeHeader = len(ENVELOPE_MAGIC) + 2
+
+func parseEnvelope(src []byte) (id string, nonce, data []byte, err error) {
+	m := len(ENVELOPE_MAGIC)
+	if len(src) < envelopeHeader || string(src[:m]) != ENVELOPE_MAGIC || src[m] != ENVELOPE_VERSION || src[m+1] == 0 {
+		return "", nil, nil, ErrBadEnvelope
+	}
+	n := envelopeHeader + int(src[m+1])
+	if len(src) < n+ENVELOPE_NONCE+ENVELOPE_TAG {
+		return "", nil, nil, ErrBadEnvelope
+	}
+	return string(src[envelopeHeader:n]), src[n : n+ENVELOPE_NONCE], src[n+ENVELOPE_NONCE:], nil
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+var (
+	testKey1 = []byte("0123456789abcdef")
+	testKey2 = []byte("abcdef0123456789abcdef0123456789")
+)
+
+func newTestRing(t *testing.T) *KeyRing {
+	t.Helper()
+	r := NewKeyRing()
+	if err := r.Add("v1", testKey1); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	r := newTestRing(t)
+	for _, plain := range [][]byte{[]byte(`{"a":1}`), {}, bytes.Repeat([]byte("x"), 1000)} {
+		data, err := r.Encrypt(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEnvelope(data) {
+			t.Fatalf("not an envelope: %x", data)
+		}
+		if id, _ := EnvelopeKeyId(data); id != "v1" {
+			t.Fatalf("key id: %q", id)
+		}
+		got, err := r.Decrypt(data)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("got %q, %v, want %q", got, err, plain)
+		}
+	}
+	//相同明文每次加密结果不同
+	a, _ := r.Encrypt([]byte("same"))
+	b, _ := r.Encrypt([]byte("same"))
+	if bytes.Equal(a, b) {
+		t.Fatal("nonce reused")
+	}
+}
+
+func TestEnvelopeRotation(t *testing.T) {
+	r := newTestRing(t)
+	old, _ := r.Encrypt([]byte("old"))
+	if err := r.Add("v2", testKey2); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPrimary("v2"); err != nil {
+		t.Fatal(err)
+	}
+	cur, _ := r.Encrypt([]byte("new"))
+	if id, _ := EnvelopeKeyId(cur); id != "v2" {
+		t.Fatalf("primary not used: %q", id)
+	}
+	if got, err := r.Decrypt(old); err != nil || string(got) != "old" {
+		t.Fatalf("old key: %q, %v", got, err)
+	}
+	if got, err := r.Decrypt(cur); err != nil || string(got) != "new" {
+		t.Fatalf("new key: %q, %v", got, err)
+	}
+
+	r.Remove("v1")
+	if _, err := r.Decrypt(old); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("removed key: %v", err)
+	}
+	if err := r.SetPrimary("v1"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("set removed primary: %v", err)
+	}
+}
+
+func TestEnvelopeTampered(t *testing.T) {
+	r := newTestRing(t)
+	//长度为16的倍数, 可以被当作 AES/ECB 数据
+	data, _ := r.Encrypt(bytes.Repeat([]byte("y"), 16))
+	for _, i := range []int{6, 8, len(data) - 1} { //密钥ID, nonce, tag
+		bad := append([]byte(nil), data...)
+		bad[i] ^= 1
+		//篡改后不能退回到 AES/ECB 解密
+		if got, err := r.Decrypt(bad); err == nil {
+			t.Fatalf("byte %d: tampered envelope decrypted to %q", i, got)
+		}
+	}
+	if _, err := r.Decrypt(data[:len(data)-1]); err == nil {
+		t.Fatal("truncated envelope decrypted")
+	}
+}
+
+func TestEnvelopeLegacy(t *testing.T) {
+	r := newTestRing(t)
+	legacy, err := AesEncrypt([]byte(`{"a":1}`), testKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Decrypt(legacy)
+	if err != nil || string(got) != `{"a":1}` {
+		t.Fatalf("legacy: %q, %v", got, err)
+	}
+
+	if err := r.SetLegacy(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Decrypt(legacy); err == nil {
+		t.Fatal("legacy accepted after SetLegacy(\"\")")
+	}
+}
+
+// 旧版密文不能被误认为信封格式, 都要能按 AES/ECB 解密
+func TestEnvelopeLegacyRandom(t *testing.T) {
+	r := newTestRing(t)
+	plain := make([]byte, 48)
+	for i := 0; i < 20000; i++ {
+		n := 1 + i%len(plain)
+		if _, err := rand.Read(plain[:n]); err != nil {
+			t.Fatal(err)
+		}
+		legacy, err := AesEncrypt(plain[:n], testKey1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Decrypt(legacy)
+		if err != nil || !bytes.Equal(got, plain[:n]) {
+			t.Fatalf("legacy %x: got %x, %v", legacy, got, err)
+		}
+	}
+}
+
+func TestAesDecryptPadding(t *testing.T) {
+	for _, plain := range []string{"a", "0123456789abcde", "0123456789abcdef"} {
+		data, _ := AesEncrypt([]byte(plain), testKey1)
+		got, err := AesDecrypt(data, testKey1)
+		if err != nil || string(got) != plain {
+			t.Fatalf("got %q, %v, want %q", got, err, plain)
+		}
+	}
+	if _, err := AesDecrypt(make([]byte, 15), testKey1); err == nil {
+		t.Fatal("partial block accepted")
+	}
+}
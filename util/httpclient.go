@@ -0,0 +1,336 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wyy8261/gmf/logger"
+)
+
+const (
+	HTTP_TIMEOUT        = 5 * time.Second        //默认单次请求超时
+	HTTP_RETRIES        = 2                      //默认重试次数
+	HTTP_RETRY_WAIT     = 200 * time.Millisecond //第一次重试前的等待, 之后每次翻倍
+	HTTP_RETRY_MAXWAIT  = 5 * time.Second
+	HTTP_MAX_BODY       = 32 << 20 //响应体最大字节数
+	HTTP_ERROR_BODY_LEN = 256      //HttpError 描述中最多显示的响应体长度
+)
+
+var gHttpClient = NewHttpClient()
+
+// HttpError 响应状态码不是2xx
+type HttpError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HttpError) Error() string {
+	body := e.Body
+	if len(body) > HTTP_ERROR_BODY_LEN {
+		body = body[:HTTP_ERROR_BODY_LEN]
+	}
+	return fmt.Sprintf("http: %s %s: status %d: %s", e.Method, logger.Redact(e.URL), e.StatusCode, body)
+}
+
+// HttpTrace 每次请求(含重试)结束后传给钩子的信息
+type HttpTrace struct {
+	Request    *http.Request
+	Attempt    int //从0开始, 大于0为重试
+	StatusCode int //没有响应时为0
+	Cost       time.Duration
+	Err        error
+}
+
+// HttpHook 请求钩子, 用于记录日志、统计等
+type HttpHook func(t *HttpTrace)
+
+// HttpClient 带超时、重试及钩子的HTTP客户端, 创建后修改字段不是并发安全的, 须在使用前设置好
+type HttpClient struct {
+	Client       *http.Client
+	BaseURL      string      //相对地址的前缀
+	Header       http.Header //每个请求都带上的请求头
+	Timeout      time.Duration
+	Retries      int
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+	//Retry 判断是否重试, err 为网络错误, 否则 resp 为状态码不是2xx的响应(响应体已读取); 为nil时使用 DefaultRetry
+	Retry func(req *http.Request, resp *http.Response, err error) bool
+	Hooks []HttpHook
+}
+
+// HttpOption 单次调用的设置
+type HttpOption func(o *httpCall)
+
+type httpCall struct {
+	timeout time.Duration
+	retries int
+	header  http.Header
+	query   url.Values
+}
+
+// NewHttpClient 默认超时 HTTP_TIMEOUT, 重试 HTTP_RETRIES 次, 并用 LogHook 记录请求日志
+func NewHttpClient() *HttpClient {
+	return &HttpClient{
+		Client:       &http.Client{},
+		Header:       make(http.Header),
+		Timeout:      HTTP_TIMEOUT,
+		Retries:      HTTP_RETRIES,
+		RetryWait:    HTTP_RETRY_WAIT,
+		RetryMaxWait: HTTP_RETRY_MAXWAIT,
+		Hooks:        []HttpHook{LogHook},
+	}
+}
+
+// DefaultHttpClient HttpGet / HttpPost 及 GetJSON 等传入nil客户端时使用的客户端
+func DefaultHttpClient() *HttpClient {
+	return gHttpClient
+}
+
+// WithTimeout 本次调用每次请求的超时, 覆盖 HttpClient.Timeout
+func WithTimeout(d time.Duration) HttpOption {
+	return func(o *httpCall) {
+		o.timeout = d
+	}
+}
+
+// WithRetries 本次调用的重试次数, 0为不重试
+func WithRetries(n int) HttpOption {
+	return func(o *httpCall) {
+		o.retries = n
+	}
+}
+
+// WithHeader 本次调用的请求头
+func WithHeader(key, value string) HttpOption {
+	return func(o *httpCall) {
+		o.header.Set(key, value)
+	}
+}
+
+// WithQuery 追加到地址上的查询参数
+func WithQuery(query url.Values) HttpOption {
+	return func(o *httpCall) {
+		for k, vs := range query {
+			for _, v := range vs {
+				o.query.Add(k, v)
+			}
+		}
+	}
+}
+
+// LogHook 成功的请求记录DEBUG日志, 失败的记录WARNING日志, 日志带有ctx中的请求ID, 地址中的密码会被脱敏
+func LogHook(t *HttpTrace) {
+	ctx := t.Request.Context()
+	u := logger.Redact(t.Request.URL.String())
+	if t.Err != nil {
+		logger.LOGWC(ctx, t.Request.Method, " ", u, ", attempt:", t.Attempt, ", status:", t.StatusCode, ", cost:", t.Cost, ", err:", t.Err)
+		return
+	}
+	logger.LOGDC(ctx, t.Request.Method, " ", u, ", attempt:", t.Attempt, ", status:", t.StatusCode, ", cost:", t.Cost)
+}
+
+// DefaultRetry 网络错误及502/503/504对幂等请求重试, 429及503对所有请求重试(服务端未处理该请求)
+func DefaultRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return idempotent(req.Method)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(req.Method)
+	}
+	return false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Do 发送请求并读取响应体, 状态码不是2xx时返回响应体及 *HttpError。
+// body 在重试时重复发送; ctx 限制包括重试在内的整个调用, 超时设置只限制单次请求
+func (c *HttpClient) Do(ctx context.Context, method, rawurl string, body []byte, opts ...HttpOption) ([]byte, http.Header, error) {
+	call := httpCall{timeout: c.Timeout, retries: c.Retries, header: make(http.Header), query: make(url.Values)}
+	for _, opt := range opts {
+		opt(&call)
+	}
+	if c.BaseURL != "" && !strings.Contains(rawurl, "://") {
+		rawurl = strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(rawurl, "/")
+	}
+	if len(call.query) > 0 {
+		sep := "?"
+		if strings.Contains(rawurl, "?") {
+			sep = "&"
+		}
+		rawurl += sep + call.query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		data, header, retry, wait, err := c.attempt(ctx, method, rawurl, body, &call, attempt)
+		if !retry || attempt >= call.retries {
+			return data, header, err
+		}
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return data, header, err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt 发送一次请求, wait 为服务端 Retry-After 要求的等待时间
+func (c *HttpClient) attempt(ctx context.Context, method, rawurl string, body []byte, call *httpCall, attempt int) (data []byte, header http.Header, retry bool, wait time.Duration, err error) {
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, reader)
+	if err != nil {
+		return nil, nil, false, 0, err
+	}
+	for k, vs := range c.Header {
+		req.Header[k] = vs
+	}
+	for k, vs := range call.header {
+		req.Header[k] = vs
+	}
+
+	trace := HttpTrace{Request: req, Attempt: attempt}
+	start := time.Now()
+	resp, err := c.Client.Do(req)
+	if err == nil {
+		trace.StatusCode = resp.StatusCode
+		header = resp.Header
+		data, err = io.ReadAll(io.LimitReader(resp.Body, HTTP_MAX_BODY))
+		resp.Body.Close()
+		if err == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+			err = &HttpError{Method: method, URL: rawurl, StatusCode: resp.StatusCode, Body: data}
+		}
+		if secs, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil && secs > 0 {
+			wait = min(time.Duration(secs)*time.Second, c.RetryMaxWait)
+		}
+	}
+	trace.Cost = time.Since(start)
+	trace.Err = err
+	for _, hook := range c.Hooks {
+		hook(&trace)
+	}
+
+	if err == nil {
+		return data, header, false, 0, nil
+	}
+	if _, ok := err.(*HttpError); ok {
+		retry = c.retryFunc()(req, resp, nil)
+	} else {
+		retry = c.retryFunc()(req, nil, err)
+	}
+	return data, header, retry, wait, err
+}
+
+func (c *HttpClient) retryFunc() func(req *http.Request, resp *http.Response, err error) bool {
+	if c.Retry != nil {
+		return c.Retry
+	}
+	return DefaultRetry
+}
+
+// backoff 第attempt次重试前的等待, 指数增长并在 [d/2, d) 内随机, 避免客户端同时重试
+func (c *HttpClient) backoff(attempt int) time.Duration {
+	d := c.RetryWait
+	for i := 0; i < attempt && d < c.RetryMaxWait; i++ {
+		d *= 2
+	}
+	if c.RetryMaxWait > 0 && d > c.RetryMaxWait {
+		d = c.RetryMaxWait
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// Get 发送GET请求, 返回响应体
+func (c *HttpClient) Get(ctx context.Context, rawurl string, opts ...HttpOption) ([]byte, error) {
+	data, _, err := c.Do(ctx, http.MethodGet, rawurl, nil, opts...)
+	return data, err
+}
+
+// Post 发送POST请求, 返回响应体
+func (c *HttpClient) Post(ctx context.Context, rawurl, contentType string, body []byte, opts ...HttpOption) ([]byte, error) {
+	opts = append([]HttpOption{WithHeader("Content-Type", contentType)}, opts...)
+	data, _, err := c.Do(ctx, http.MethodPost, rawurl, body, opts...)
+	return data, err
+}
+
+// DoJSON 把in编码为JSON作为请求体(in为nil时没有请求体), 响应体解码到out(out为nil时不解码)
+func (c *HttpClient) DoJSON(ctx context.Context, method, rawurl string, in, out interface{}, opts ...HttpOption) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+		opts = append([]HttpOption{WithHeader("Content-Type", "application/json")}, opts...)
+	}
+	opts = append([]HttpOption{WithHeader("Accept", "application/json")}, opts...)
+	data, _, err := c.Do(ctx, method, rawurl, body, opts...)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("http: %s %s: decode response: %w", method, logger.Redact(rawurl), err)
+	}
+	return nil
+}
+
+// GetJSON 发送GET请求, 响应体按JSON解码为T; c为nil时使用 DefaultHttpClient
+func GetJSON[T any](ctx context.Context, c *HttpClient, rawurl string, opts ...HttpOption) (*T, error) {
+	if c == nil {
+		c = gHttpClient
+	}
+	res := new(T)
+	if err := c.DoJSON(ctx, http.MethodGet, rawurl, nil, res, opts...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PostJSON 把req编码为JSON后POST, 响应体按JSON解码为T; c为nil时使用 DefaultHttpClient
+func PostJSON[T any](ctx context.Context, c *HttpClient, rawurl string, req interface{}, opts ...HttpOption) (*T, error) {
+	if c == nil {
+		c = gHttpClient
+	}
+	res := new(T)
+	if err := c.DoJSON(ctx, http.MethodPost, rawurl, req, res, opts...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,283 @@
+package util
+
+import (
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wyy8261/gmf/logger"
+)
+
+var gScheduler = NewScheduler()
+
+// Job 定时任务, 由 Scheduler 的 AddCron / AddInterval / AddJob 创建
+type Job struct {
+	name     string
+	schedule Schedule
+	fn       func()
+	jitter   time.Duration
+	overlap  bool //允许上一次未结束时再次运行
+	owner    *Scheduler
+
+	running atomic.Int32 //正在进行的运行数
+	wg      sync.WaitGroup
+	stop    chan struct{}
+	once    sync.Once
+
+	mutex    sync.Mutex
+	lastRun  time.Time
+	lastCost time.Duration
+	nextRun  time.Time
+	runs     int64
+	skipped  int64
+	stopped  bool
+}
+
+// JobOption 任务设置
+type JobOption func(j *Job)
+
+// WithJobName 任务名称, 用于日志, 默认为函数名
+func WithJobName(name string) JobOption {
+	return func(j *Job) {
+		j.name = name
+	}
+}
+
+// WithJitter 每次运行随机推迟 [0, d), 避免多个实例同时运行
+func WithJitter(d time.Duration) JobOption {
+	return func(j *Job) {
+		j.jitter = d
+	}
+}
+
+// WithOverlap 允许上一次运行未结束时开始下一次, 默认跳过这一次
+func WithOverlap() JobOption {
+	return func(j *Job) {
+		j.overlap = true
+	}
+}
+
+// Name 任务名称
+func (j *Job) Name() string {
+	return j.name
+}
+
+// LastRun 上一次开始运行的时间, 未运行过时为零值
+func (j *Job) LastRun() time.Time {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.lastRun
+}
+
+// LastCost 上一次运行的耗时
+func (j *Job) LastCost() time.Duration {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.lastCost
+}
+
+// NextRun 下一次运行的时间(含随机推迟), 已停止或不再运行时为零值
+func (j *Job) NextRun() time.Time {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.nextRun
+}
+
+// Runs 已运行的次数
+func (j *Job) Runs() int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.runs
+}
+
+// Skipped 因上一次未结束而跳过的次数
+func (j *Job) Skipped() int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.skipped
+}
+
+// Running 是否正在运行
+func (j *Job) Running() bool {
+	return j.running.Load() > 0
+}
+
+// Stop 停止任务, 正在进行的运行不会被打断; 可重复调用
+func (j *Job) Stop() {
+	j.once.Do(func() {
+		j.mutex.Lock()
+		j.stopped = true
+		j.mutex.Unlock()
+		close(j.stop)
+		j.owner.remove(j)
+	})
+}
+
+// StopWait 停止任务并等待正在进行的运行结束
+func (j *Job) StopWait() {
+	j.Stop()
+	j.wg.Wait()
+}
+
+func (j *Job) loop() {
+	base := time.Now()
+	for {
+		next := j.schedule.Next(base)
+		if next.IsZero() {
+			j.setNext(time.Time{})
+			j.Stop()
+			return
+		}
+		//机器休眠等原因错过的运行不再补跑
+		if now := time.Now(); next.Before(now) {
+			next = j.schedule.Next(now)
+		}
+		base = next
+		if j.jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(j.jitter))))
+		}
+		j.setNext(next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-j.stop:
+			timer.Stop()
+			j.setNext(time.Time{})
+			return
+		case <-timer.C:
+		}
+		j.fire()
+	}
+}
+
+func (j *Job) setNext(t time.Time) {
+	j.mutex.Lock()
+	j.nextRun = t
+	j.mutex.Unlock()
+}
+
+// fire 在新协程中运行一次, 上一次未结束且不允许重叠时跳过
+func (j *Job) fire() {
+	if j.overlap {
+		j.running.Add(1)
+	} else if !j.running.CompareAndSwap(0, 1) {
+		j.mutex.Lock()
+		j.skipped++
+		j.mutex.Unlock()
+		logger.LOGW("job:", j.name, ", previous run not finished, skipped")
+		return
+	}
+	start := time.Now()
+	j.mutex.Lock()
+	if j.stopped {
+		//与 StopWait 同时发生, 不再运行
+		j.mutex.Unlock()
+		j.running.Add(-1)
+		return
+	}
+	j.lastRun = start
+	j.runs++
+	j.wg.Add(1)
+	j.mutex.Unlock()
+
+	go func() {
+		defer j.done(start)
+		defer logger.Recover()
+		j.fn()
+	}()
+}
+
+func (j *Job) done(start time.Time) {
+	j.mutex.Lock()
+	j.lastCost = time.Since(start)
+	j.mutex.Unlock()
+	j.running.Add(-1)
+	j.wg.Done()
+}
+
+// Scheduler 管理一组定时任务, 每个任务的每次运行都在独立的协程中并捕获panic
+type Scheduler struct {
+	mutex sync.Mutex
+	jobs  map[*Job]struct{}
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{jobs: make(map[*Job]struct{})}
+}
+
+// AddCron 按cron表达式运行fn, 表达式格式见 ParseCron
+func (s *Scheduler) AddCron(spec string, fn func(), opts ...JobOption) (*Job, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return s.AddJob(schedule, fn, opts...), nil
+}
+
+// AddInterval 每隔d运行一次fn, 第一次运行在d之后
+func (s *Scheduler) AddInterval(d time.Duration, fn func(), opts ...JobOption) *Job {
+	return s.AddJob(IntervalSchedule(d), fn, opts...)
+}
+
+// AddJob 按schedule运行fn
+func (s *Scheduler) AddJob(schedule Schedule, fn func(), opts ...JobOption) *Job {
+	j := &Job{
+		schedule: schedule,
+		fn:       fn,
+		owner:    s,
+		stop:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	if j.name == "" {
+		j.name = runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	}
+	s.mutex.Lock()
+	s.jobs[j] = struct{}{}
+	s.mutex.Unlock()
+	go j.loop()
+	return j
+}
+
+// Jobs 未停止的任务
+func (s *Scheduler) Jobs() []*Job {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make([]*Job, 0, len(s.jobs))
+	for j := range s.jobs {
+		res = append(res, j)
+	}
+	return res
+}
+
+// Stop 停止所有任务并等待正在进行的运行结束
+func (s *Scheduler) Stop() {
+	for _, j := range s.Jobs() {
+		j.StopWait()
+	}
+}
+
+func (s *Scheduler) remove(j *Job) {
+	s.mutex.Lock()
+	delete(s.jobs, j)
+	s.mutex.Unlock()
+}
+
+// AddCron 在默认调度器中添加cron任务
+func AddCron(spec string, fn func(), opts ...JobOption) (*Job, error) {
+	return gScheduler.AddCron(spec, fn, opts...)
+}
+
+// AddInterval 在默认调度器中添加固定间隔的任务
+func AddInterval(d time.Duration, fn func(), opts ...JobOption) *Job {
+	return gScheduler.AddInterval(d, fn, opts...)
+}
+
+// DefaultScheduler AddCron / AddInterval / SetTimer 使用的调度器
+func DefaultScheduler() *Scheduler {
+	return gScheduler
+}
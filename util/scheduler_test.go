@@ -0,0 +1,95 @@
+package util
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor 轮询直到cond成立, 超时失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerSkipAndStopWait(t *testing.T) {
+	s := NewScheduler()
+	release := make(chan struct{})
+	var active atomic.Int32
+	j := s.AddInterval(5*time.Millisecond, func() {
+		active.Add(1)
+		<-release
+		active.Add(-1)
+	}, WithJobName("slow"))
+
+	//上一次未结束时跳过, 不会并发运行
+	waitFor(t, "skipped runs", func() bool { return j.Skipped() >= 2 })
+	if j.Runs() != 1 || active.Load() != 1 || !j.Running() {
+		t.Fatalf("runs %d, active %d, running %v", j.Runs(), active.Load(), j.Running())
+	}
+
+	//StopWait 等待正在进行的运行结束
+	stopped := make(chan struct{})
+	go func() {
+		j.StopWait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("StopWait returned before the run finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	if j.Running() || !j.NextRun().IsZero() || len(s.Jobs()) != 0 {
+		t.Fatalf("running %v, next %v, jobs %d", j.Running(), j.NextRun(), len(s.Jobs()))
+	}
+	runs := j.Runs()
+	time.Sleep(20 * time.Millisecond)
+	if j.Runs() != runs {
+		t.Fatalf("ran after StopWait: %d -> %d", runs, j.Runs())
+	}
+}
+
+func TestSchedulerOverlapAndStop(t *testing.T) {
+	s := NewScheduler()
+	release := make(chan struct{})
+	var active, maxActive atomic.Int32
+	overlap := s.AddInterval(5*time.Millisecond, func() {
+		n := active.Add(1)
+		for m := maxActive.Load(); n > m && !maxActive.CompareAndSwap(m, n); m = maxActive.Load() {
+		}
+		<-release
+		active.Add(-1)
+	}, WithOverlap())
+	var ticks atomic.Int32
+	quick := s.AddInterval(5*time.Millisecond, func() { ticks.Add(1) })
+
+	//允许重叠时上一次未结束也会运行
+	waitFor(t, "overlapping runs", func() bool { return maxActive.Load() >= 2 })
+	waitFor(t, "quick runs", func() bool { return ticks.Load() >= 2 })
+	if overlap.Skipped() != 0 {
+		t.Fatalf("overlap job skipped %d", overlap.Skipped())
+	}
+
+	//Scheduler.Stop 停止所有任务并等待运行结束
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	s.Stop()
+	if active.Load() != 0 || overlap.Running() || quick.Running() || len(s.Jobs()) != 0 {
+		t.Fatalf("active %d after Stop, jobs %d", active.Load(), len(s.Jobs()))
+	}
+	n := ticks.Load()
+	time.Sleep(20 * time.Millisecond)
+	if ticks.Load() != n {
+		t.Fatal("job ran after Stop")
+	}
+}
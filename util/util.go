@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	rand2 "crypto/rand"
@@ -12,24 +13,18 @@ import (
 	"github.com/dlclark/regexp2"
 	"github.com/wyy8261/gmf/logger"
 	"io"
-	"io/ioutil"
 	"math/big"
-	"net/http"
 	"strconv"
 	"time"
 )
 
-func SetTimer(dura time.Duration, proc func()) {
-	proc()
-	go func(d time.Duration, f func()) {
-		ticker := time.NewTicker(d)
-		for {
-			select {
-			case <-ticker.C:
-				go f()
-			}
-		}
-	}(dura, proc)
+// SetTimer 立即运行一次proc, 之后每隔dura运行一次, 上一次未结束时跳过; 返回的任务可用于停止
+func SetTimer(dura time.Duration, proc func()) *Job {
+	func() {
+		defer logger.Recover()
+		proc()
+	}()
+	return AddInterval(dura, proc)
 }
 
 func Base642File(base64Str string) (io.Reader, error) {
@@ -78,13 +73,18 @@ func PKCS7UnPadding(src []byte) ([]byte, error) {
 		return nil, errors.New("invalid padding")
 	}
 	padding := int(src[length-1])
-	if padding < 1 || padding > aes.BlockSize {
+	if padding < 1 || padding > aes.BlockSize || padding > length {
 		return nil, errors.New("invalid padding")
 	}
+	for _, by := range src[length-padding:] {
+		if int(by) != padding {
+			return nil, errors.New("invalid padding")
+		}
+	}
 	return src[:length-padding], nil
 }
 
-// Aes/ECB模式的加密方法，PKCS7填充方式
+// Aes/ECB模式的加密方法，PKCS7填充方式, 仅用于兼容旧客户端, 新数据请使用 KeyRing
 func AesEncrypt(src, key []byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, errors.New("key empty")
@@ -111,7 +111,7 @@ func AesEncrypt(src, key []byte) ([]byte, error) {
 	return ciphertext, nil
 }
 
-// Aes/ECB模式的解密方法，PKCS7填充方式
+// Aes/ECB模式的解密方法，PKCS7填充方式, 新数据请使用 KeyRing
 func AesDecrypt(src, key []byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, errors.New("key empty")
@@ -121,12 +121,15 @@ func AesDecrypt(src, key []byte) ([]byte, error) {
 		return nil, err
 	}
 	if len(src) == 0 {
-		return nil, errors.New("plaintext empty")
+		return nil, errors.New("ciphertext empty")
+	}
+	if len(src)%Block.BlockSize() != 0 {
+		return nil, errors.New("ciphertext not full blocks")
 	}
 	mode := NewECBDecrypter(Block)
-	ciphertext := src
-	mode.CryptBlocks(ciphertext, ciphertext)
-	return ciphertext, nil
+	plaintext := make([]byte, len(src)) // 不修改调用方的 src
+	mode.CryptBlocks(plaintext, src)
+	return PKCS7UnPadding(plaintext)
 }
 
 // ECB模式结构体
@@ -193,48 +196,37 @@ func (x *ecbDecrypter) CryptBlocks(dst, src []byte) {
 	}
 }
 
+// 发送GET请求, 超时3秒, 不重试; 返回响应体(包括非2xx的响应), 网络错误时记录日志并返回空字符串。
+// 需要错误或状态码时使用 HttpClient
 func HttpGet(url string) string {
-	// 超时时间：3秒
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		logger.LOGE("err:", err)
-		return ""
-	}
-	defer resp.Body.Close()
-	var buffer = make([]byte, 0)
-	result := bytes.NewBuffer(nil)
-	for {
-		n, err := resp.Body.Read(buffer)
-		result.Write(buffer[:n])
-		if n == 0 || (err != nil && err == io.EOF) {
-			break
-		} else if err != nil {
-			return ""
-		}
-	}
-	return result.String()
+	data, err := gHttpClient.Get(context.Background(), url, WithTimeout(3*time.Second), WithRetries(0))
+	return httpResult(data, err)
 }
 
-// 发送POST请求
+// 发送POST请求, 超时2秒, 不重试
 // url：         请求地址
-// data：        POST请求提交的数据
+// data：        POST请求提交的数据, 按JSON编码
 // contentType： 请求体格式，如：application/json
-// content：     请求放回的内容
+// content：     请求放回的内容(包括非2xx的响应), 网络错误时为空字符串
 func HttpPost(url string, data interface{}, contentType string) string {
-
-	// 超时时间：5秒
-	client := &http.Client{Timeout: 2 * time.Second}
-	jsonStr, _ := json.Marshal(data)
-	fmt.Println("json:", string(jsonStr))
-	resp, err := client.Post(url, contentType, bytes.NewBuffer(jsonStr))
+	jsonStr, err := json.Marshal(data)
 	if err != nil {
-		panic(err)
+		logger.LOGE("err:", err)
+		return ""
 	}
-	defer resp.Body.Close()
+	result, err := gHttpClient.Post(context.Background(), url, contentType, jsonStr, WithTimeout(2*time.Second), WithRetries(0))
+	return httpResult(result, err)
+}
 
-	result, _ := ioutil.ReadAll(resp.Body)
-	return string(result)
+// httpResult HttpGet/HttpPost 的返回值: 记录错误, 状态码不是2xx时仍返回响应体
+func httpResult(data []byte, err error) string {
+	if err != nil {
+		logger.LOGE("err:", err)
+		if _, ok := err.(*HttpError); !ok {
+			return ""
+		}
+	}
+	return string(data)
 }
 
 func Int642string(val int64) string {
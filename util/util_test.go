@@ -0,0 +1,34 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHttpWrappers(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("busy"))
+	}))
+	defer srv.Close()
+
+	//非2xx时两者都返回响应体, 且不重试
+	if got := HttpGet(srv.URL); got != "busy" || hits.Load() != 1 {
+		t.Fatalf("get: %q, hits %d", got, hits.Load())
+	}
+	if got := HttpPost(srv.URL, map[string]int{"a": 1}, "application/json"); got != "busy" || hits.Load() != 2 {
+		t.Fatalf("post: %q, hits %d", got, hits.Load())
+	}
+
+	srv.Close()
+	if got := HttpGet(srv.URL); got != "" {
+		t.Fatalf("get after close: %q", got)
+	}
+	if got := HttpPost(srv.URL, nil, "application/json"); got != "" {
+		t.Fatalf("post after close: %q", got)
+	}
+}